@@ -0,0 +1,179 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// arrayLenStr renders the length of an array type, the constants declared
+// in the structure package being qualified with its alias.
+func arrayLenStr(lenExpr ast.Expr, packageAlias string) string {
+	switch t := lenExpr.(type) {
+	case *ast.BasicLit:
+		return t.Value
+	case *ast.Ident:
+		if len(packageAlias) != 0 && unicode.IsUpper(rune(t.Name[0])) {
+			return fmt.Sprintf("%s.%s", packageAlias, t.Name)
+		}
+		return t.Name
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, token.NewFileSet(), lenExpr); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// splitArrayType returns the length and the element type of a fixed array
+// type, the known named arrays such as uuid.UUID included.
+func splitArrayType(typeStr string) (length, elem string, ok bool) {
+	if underlying, exist := knownArrayType(typeStr); exist {
+		typeStr = underlying
+	}
+	if !strings.HasPrefix(typeStr, "[") || strings.HasPrefix(typeStr, "[]") {
+		return "", "", false
+	}
+	end := strings.Index(typeStr, "]")
+	if end < 0 {
+		return "", "", false
+	}
+	return typeStr[1:end], typeStr[end+1:], true
+}
+
+// sequenceElem returns the element type of a slice or a fixed array type,
+// array telling which of them it is.
+func sequenceElem(typeStr string) (elem string, array bool, ok bool) {
+	if _, elem, array := splitArrayType(typeStr); array {
+		return elem, true, true
+	}
+	if strings.HasPrefix(typeStr, "[]") {
+		return strings.TrimPrefix(typeStr, "[]"), false, true
+	}
+	return "", false, false
+}
+
+// castArray converts fixed arrays into slices and arrays of the same length,
+// element-wise when the element types differ. Slices don't convert into
+// arrays here, a slice of another length fails the conversion, see
+// castSliceArrayField.
+func castArray(srcRow, srcType, dstType string, options castOptions) (string, bool) {
+	srcElem, srcArray, srcOk := sequenceElem(srcType)
+	dstElem, dstArray, dstOk := sequenceElem(dstType)
+	if !srcOk || !dstOk || !srcArray {
+		return srcRow, false
+	}
+	srcLen, _, _ := splitArrayType(srcType)
+	dstLen, _, _ := splitArrayType(dstType)
+	if srcArray && dstArray && srcLen != dstLen {
+		return srcRow, false
+	}
+	elemStr, ok := castRow("v", srcElem, dstElem, options)
+	if !ok {
+		return srcRow, false
+	}
+	useArrayImports(srcType, dstType)
+	if strings.HasPrefix(srcElem, "*") && elemStr != "v" {
+		elemStr = fmt.Sprintf("if v != nil { d[i] = %s }", elemStr)
+	} else {
+		elemStr = fmt.Sprintf("d[i] = %s", elemStr)
+	}
+	switch {
+	case srcArray && dstArray && srcElem == dstElem:
+		return fmt.Sprintf("%s(%s)", dstType, srcRow), true
+	case !dstArray && srcElem == dstElem:
+		return fmt.Sprintf("func(s %s) %s { return s[:] }(%s)", srcType, dstType, srcRow), true
+	case !dstArray:
+		return fmt.Sprintf("func(s %s) %s { d := make(%s, len(s)); for i, v := range s { %s }; return d }(%s)",
+			srcType, dstType, dstType, elemStr, srcRow), true
+	}
+	return fmt.Sprintf("func(s %s) (d %s) { for i, v := range s { %s }; return d }(%s)", srcType, dstType, elemStr, srcRow), true
+}
+
+// castCheckedArray returns an expression evaluating to the converted array
+// or slice and an error when the length of the slice differs from the
+// length of the destination array or an element is narrowed with a loss.
+func castCheckedArray(srcRow, srcType, dstType string, options castOptions) (string, bool) {
+	srcElem, srcArray, srcOk := sequenceElem(srcType)
+	dstElem, dstArray, dstOk := sequenceElem(dstType)
+	if !srcOk || !dstOk || (!srcArray && !dstArray) {
+		return "", false
+	}
+	srcLen, _, _ := splitArrayType(srcType)
+	dstLen, _, _ := splitArrayType(dstType)
+	narrowing := isNarrowing(srcElem, dstElem)
+	lengthChecked := !srcArray && dstArray
+	switch {
+	case srcArray && dstArray && srcLen != dstLen:
+		return "", false
+	case !narrowing && !lengthChecked:
+		return "", false
+	}
+	var body []string
+	if !dstArray {
+		body = append(body, fmt.Sprintf("d = make(%s, len(s))", dstType))
+	}
+	if lengthChecked {
+		body = append(body, "if len(s) != len(d) { return d, fmt.Errorf(\"%d elements can't be converted to %T\", len(s), d) }")
+	}
+	if narrowing {
+		body = append(body, fmt.Sprintf("for i, v := range s { c, err := %s(v); if err != nil { return d, fmt.Errorf(\"index %%d: %%w\", i, err) }; d[i] = c }",
+			checkFuncStr(srcElem, dstElem, options)))
+	} else {
+		elemStr, ok := castRow("v", srcElem, dstElem, options)
+		if !ok || strings.HasPrefix(srcElem, "*") {
+			return "", false
+		}
+		if srcElem == dstElem {
+			body = append(body, "copy(d[:], s)")
+		} else {
+			body = append(body, fmt.Sprintf("for i, v := range s { d[i] = %s }", elemStr))
+		}
+	}
+	useArrayImports(srcType, dstType)
+	return fmt.Sprintf("func(s %s) (d %s, err error) { %s; return d, nil }(%s)", srcType, dstType, strings.Join(body, "; "), srcRow), true
+}
+
+// castSliceArrayField fills the rule converting the slice source field into
+// the fixed array destination field. A slice of another length fails the
+// mapper, so mappers not returning an error leave the field unmapped.
+func castSliceArrayField(rule fieldMappingRule, srcAlias string, srcField, dstField field, options castOptions) (fieldMappingRule, bool) {
+	_, srcArray, srcOk := sequenceElem(srcField.TypeStr)
+	_, dstArray, dstOk := sequenceElem(dstField.TypeStr)
+	if !srcOk || !dstOk || srcArray || !dstArray {
+		return rule, false
+	}
+	if !options.returnsError {
+		log.Printf("%s: destination field %s left unmapped, converting %s into %s may fail and the mapper returns no error",
+			options.mapperName, dstField.Name, srcField.TypeStr, dstField.TypeStr)
+		return rule, false
+	}
+	castStr, ok := castCheckedArray(fmt.Sprintf("%s.%s", srcAlias, srcField.Name), srcField.TypeStr, dstField.TypeStr, options)
+	if !ok {
+		return rule, false
+	}
+	rule.CastStr, rule.Checked, rule.Casted = castStr, true, true
+	return rule, true
+}
+
+// isComparableArray reports whether the type is a fixed array of numbers,
+// strings or booleans, its zero value being told by comparison.
+func isComparableArray(typeStr string) bool {
+	_, elem, ok := splitArrayType(typeStr)
+	if !ok {
+		return false
+	}
+	if _, numeric := numericTypeBits[elem]; numeric {
+		return true
+	}
+	return elem == "string" || elem == "bool" || isComparableArray(elem)
+}
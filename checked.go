@@ -0,0 +1,134 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// numericTypeBits holds the smallest and the largest size of numeric types,
+// int and uint differ between platforms.
+var numericTypeBits = map[string][2]int{
+	"byte": {8, 8}, "uint8": {8, 8}, "uint16": {16, 16}, "uint32": {32, 32}, "uint64": {64, 64}, "uint": {32, 64},
+	"int8": {8, 8}, "int16": {16, 16}, "int32": {32, 32}, "int64": {64, 64}, "int": {32, 64},
+	"float32": {32, 32}, "float64": {64, 64},
+}
+
+type checkedCast struct {
+	Src   string
+	Dst   string
+	Float bool
+}
+
+// checkedCastsList lists the conversions a checked mapper can't perform
+// without a possible loss.
+func checkedCastsList() []checkedCast {
+	var list []checkedCast
+	for _, srcType := range numericTypes {
+		for _, dstType := range numericTypes {
+			if isNarrowing(srcType, dstType) {
+				list = append(list, checkedCast{
+					Src:   srcType,
+					Dst:   dstType,
+					Float: isFloat(srcType) && isFloat(dstType),
+				})
+			}
+		}
+	}
+	return list
+}
+
+func isFloat(typeStr string) bool {
+	return strings.HasPrefix(typeStr, "float")
+}
+
+func isUnsigned(typeStr string) bool {
+	return typeStr == "byte" || strings.HasPrefix(typeStr, "uint")
+}
+
+// isNarrowing reports whether converting srcType into dstType may overflow
+// or truncate the value. Integers converted into floats are never narrowing.
+func isNarrowing(srcType, dstType string) bool {
+	srcBits, srcOk := numericTypeBits[srcType]
+	dstBits, dstOk := numericTypeBits[dstType]
+	if !srcOk || !dstOk || srcType == dstType {
+		return false
+	}
+	switch {
+	case isFloat(dstType):
+		return isFloat(srcType) && srcBits[1] > dstBits[0]
+	case isFloat(srcType):
+		return true
+	case !isUnsigned(srcType) && isUnsigned(dstType):
+		return true
+	case isUnsigned(srcType) && !isUnsigned(dstType):
+		return srcBits[1] >= dstBits[0]
+	}
+	return srcBits[1] > dstBits[0]
+}
+
+// checkFuncStr returns the function converting the numeric value of srcType
+// into dstType and reporting an error on a loss.
+func checkFuncStr(srcType, dstType string, options castOptions) string {
+	if options.inlineHelpers {
+		return fmt.Sprintf("%sTo%sChecked", srcType, strings.Title(dstType))
+	}
+	return fmt.Sprintf("%s.CheckedConvert[%s, %s]", useImport("mapping", mappingPackage), srcType, dstType)
+}
+
+// castCheckedField returns an expression evaluating to the converted value
+// and an error for narrowing numeric conversions and for slices converted
+// into fixed arrays. castAddr tells the value must be assigned by its
+// address.
+func castCheckedField(srcAlias string, srcField, dstField field, options castOptions) (castStr string, castAddr bool, ok bool) {
+	srcRow := fmt.Sprintf("%s.%s", srcAlias, srcField.Name)
+	srcType, dstType := srcField.TypeStr, dstField.TypeStr
+	if castStr, ok := castCheckedArray(srcRow, srcType, dstType, options); ok {
+		return castStr, false, true
+	}
+	srcSlice, dstSlice := strings.HasPrefix(srcType, "[]"), strings.HasPrefix(dstType, "[]")
+	if srcSlice != dstSlice {
+		return "", false, false
+	}
+	srcType, dstType = strings.TrimPrefix(srcType, "[]"), strings.TrimPrefix(dstType, "[]")
+	srcPtr, dstPtr := strings.HasPrefix(srcType, "*"), strings.HasPrefix(dstType, "*")
+	srcType, dstType = strings.TrimPrefix(srcType, "*"), strings.TrimPrefix(dstType, "*")
+	if !isNarrowing(srcType, dstType) {
+		return "", false, false
+	}
+	checkFunc := checkFuncStr(srcType, dstType, options)
+	if !srcSlice {
+		if srcPtr {
+			srcRow = "*" + srcRow
+		}
+		return fmt.Sprintf("%s(%s)", checkFunc, srcRow), dstPtr, true
+	}
+
+	if !options.inlineHelpers {
+		mapping := useImport("mapping", mappingPackage)
+		if srcPtr {
+			srcRow = fmt.Sprintf("%s.DerefSlice(%s)", mapping, srcRow)
+		}
+		if dstPtr {
+			checkFunc = fmt.Sprintf("func(v %s) (*%s, error) { c, err := %s(v); return &c, err }", srcType, dstType, checkFunc)
+		}
+		return fmt.Sprintf("%s.TryMapSlice(%s, %s)", mapping, srcRow, checkFunc), false, true
+	}
+
+	srcElem, dstElem, value, assign := srcType, dstType, "v", "c"
+	if srcPtr {
+		srcElem, value = "*"+srcElem, "*v"
+	}
+	if dstPtr {
+		dstElem, assign = "*"+dstElem, "&c"
+	}
+	loop := fmt.Sprintf("c, err := %s(%s); if err != nil { return nil, fmt.Errorf(\"index %%d: %%w\", i, err) }; d[i] = %s", checkFunc, value, assign)
+	if srcPtr {
+		loop = fmt.Sprintf("if v == nil { continue }; %s", loop)
+	}
+	return fmt.Sprintf("func(s []%s) ([]%s, error) { d := make([]%s, len(s)); for i, v := range s { %s }; return d, nil }(%s)",
+		srcElem, dstElem, dstElem, loop, srcRow), false, true
+}
@@ -0,0 +1,203 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+// cloneHelperMappers returns the deep copy mappers of the structures the
+// deep copy mappers reach through their fields, transitively, unless a
+// mapper of the structure into itself is configured. The helpers are as
+// cycle-aware and depth limited as the mapper reaching them first.
+func cloneHelperMappers(dir string, mappers []mapperConfig) ([]mapperConfig, error) {
+	known := make(map[string]bool, len(mappers))
+	names := make(map[string]bool, len(mappers))
+	type reached struct {
+		meta *structMeta
+		root mapperConfig
+	}
+	var queue []reached
+	for _, mapperConfig := range mappers {
+		names[mapperConfig.MapperName()] = true
+		if len(mapperConfig.Sources) != 1 {
+			continue
+		}
+		dstMeta, err := parseStructure(dir, mapperConfig.Destination.Path)
+		if err != nil {
+			return nil, err
+		}
+		srcMeta, err := parseStructure(dir, mapperConfig.Sources[0].Path)
+		if err != nil {
+			return nil, err
+		}
+		if shortPath(srcMeta) != shortPath(dstMeta) {
+			continue
+		}
+		known[shortPath(dstMeta)] = true
+		if mapperConfig.DeepCopy {
+			queue = append(queue, reached{meta: dstMeta, root: mapperConfig})
+		}
+	}
+
+	var helpers []mapperConfig
+	for len(queue) != 0 {
+		meta, root := queue[0].meta, queue[0].root
+		queue = queue[1:]
+		for _, path := range reachedStructures(dir, meta) {
+			nestedMeta, err := parseStructure(dir, path)
+			if err != nil || known[shortPath(nestedMeta)] {
+				continue
+			}
+			if ts, exist := nestedMeta.types[nestedMeta.name]; !exist || !isStructType(ts) {
+				continue
+			}
+			known[shortPath(nestedMeta)] = true
+			queue = append(queue, reached{meta: nestedMeta, root: root})
+
+			helper := mapperConfig{
+				Alias:       nestedMeta.name,
+				DeepCopy:    true,
+				Cycles:      root.Cycles,
+				MaxDepth:    root.MaxDepth,
+				cloneHelper: true,
+				Destination: sourceConfig{Alias: "dst", Path: path},
+				Sources:     []sourceConfig{{Alias: "src", Path: path}},
+			}
+			if names[helper.MapperName()] {
+				helper.Alias = strings.Title(getPackageAlias(nestedMeta.packagePath)) + nestedMeta.name
+			}
+			names[helper.MapperName()] = true
+			helpers = append(helpers, helper)
+		}
+	}
+	return helpers, nil
+}
+
+// reachedStructures returns the paths of the named types the structure
+// fields hold by value, by pointer or as elements of slices and maps.
+func reachedStructures(dir string, meta *structMeta) []string {
+	if len(meta.fileLocation) == 0 || len(meta.typeArgs) != 0 {
+		return nil
+	}
+	fileAST, err := parser.ParseFile(token.NewFileSet(), meta.fileLocation, nil, parser.ImportsOnly)
+	if err != nil {
+		return nil
+	}
+	resolver := typePathResolver{
+		dir:     dir,
+		fileDir: filepath.Dir(meta.fileLocation),
+		imports: fileImports(fileAST),
+	}
+	var paths []string
+	for _, f := range meta.fields {
+		typeExpr := f.typeAST
+		for {
+			switch t := typeExpr.(type) {
+			case *ast.StarExpr:
+				typeExpr = t.X
+				continue
+			case *ast.ArrayType:
+				typeExpr = t.Elt
+				continue
+			case *ast.MapType:
+				typeExpr = t.Value
+				continue
+			}
+			break
+		}
+		if path, err := resolver.typePath(typeExpr); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+func isStructType(ts *ast.TypeSpec) bool {
+	_, ok := ts.Type.(*ast.StructType)
+	return ok
+}
+
+// castCloneField copies the source field without sharing the memory it
+// references.
+func castCloneField(srcAlias string, srcField field, options castOptions) string {
+	srcRow := fmt.Sprintf("%s.%s", srcAlias, srcField.Name)
+	if castStr, ok := cloneNestedStr(srcRow, srcField.TypeStr, options); ok {
+		return castStr
+	}
+	castStr, typed := cloneStr(srcRow, srcField.Underlying, options)
+	switch {
+	case len(castStr) == 0:
+		return srcRow
+	case srcField.TypeStr != srcField.Underlying && !typed:
+		return fmt.Sprintf("%s(%s)", srcField.TypeStr, castStr)
+	}
+	return castStr
+}
+
+// cloneStr returns the expression copying the value of the type, the empty
+// string when assigning the value copies it. typed reports whether the
+// expression keeps the type of the row, the generic copies of the mapping
+// package do, so it needs no conversion into the named type of the row.
+func cloneStr(row, typeStr string, options castOptions) (castStr string, typed bool) {
+	if castStr, ok := cloneNestedStr(row, typeStr, options); ok {
+		return castStr, true
+	}
+	switch {
+	case strings.HasPrefix(typeStr, "*"):
+		elem := strings.TrimPrefix(typeStr, "*")
+		elemStr, _ := cloneStr("*v", elem, options)
+		if len(elemStr) == 0 {
+			return fmt.Sprintf("%s.ClonePtr(%s)", useImport("mapping", mappingPackage), row), false
+		}
+		return fmt.Sprintf("func(v %s) %s { if v == nil { return nil }; c := %s; return &c }(%s)", typeStr, typeStr, elemStr, row), false
+	case strings.HasPrefix(typeStr, "[]"):
+		elem := strings.TrimPrefix(typeStr, "[]")
+		if elemFunc := cloneFuncStr(elem, options); len(elemFunc) != 0 {
+			return fmt.Sprintf("%s.MapSlice(%s, %s)", useImport("mapping", mappingPackage), row, elemFunc), false
+		}
+		return fmt.Sprintf("%s.CloneSlice(%s)", useImport("mapping", mappingPackage), row), true
+	case strings.HasPrefix(typeStr, "["):
+		_, elem, _ := splitArrayType(typeStr)
+		if elemStr, _ := cloneStr("v", elem, options); len(elemStr) != 0 {
+			return fmt.Sprintf("func(s %s) %s { for i, v := range s { s[i] = %s }; return s }(%s)", typeStr, typeStr, elemStr, row), false
+		}
+	case strings.HasPrefix(typeStr, "map["):
+		_, value := splitMapType(typeStr)
+		if valueFunc := cloneFuncStr(value, options); len(valueFunc) != 0 {
+			return fmt.Sprintf("%s.MapMap(%s, %s)", useImport("mapping", mappingPackage), row, valueFunc), false
+		}
+		return fmt.Sprintf("%s.CloneMap(%s)", useImport("mapping", mappingPackage), row), true
+	}
+	return "", false
+}
+
+// cloneFuncStr returns the function copying values of the type, the empty
+// string when assigning the value copies it.
+func cloneFuncStr(typeStr string, options castOptions) string {
+	castStr, _ := cloneStr("v", typeStr, options)
+	if len(castStr) == 0 {
+		return ""
+	}
+	if mapper, exist := options.mappers[[2]string{strings.TrimPrefix(typeStr, "*"), strings.TrimPrefix(typeStr, "*")}]; exist && castStr == fmt.Sprintf("%s(v)", mapper.MapperFuncName) {
+		return mapper.MapperFuncName
+	}
+	return fmt.Sprintf("func(v %s) %s { return %s }", typeStr, typeStr, castStr)
+}
+
+// cloneNestedStr calls the mapper of the structure into itself.
+func cloneNestedStr(row, typeStr string, options castOptions) (string, bool) {
+	mapper, exist := options.nestedMapper(typeStr, typeStr)
+	if !exist || mapper.ReturnsError {
+		return "", false
+	}
+	castStr, _, ok := nestedCallStr(row, typeStr, typeStr, mapper, options)
+	return castStr, ok
+}
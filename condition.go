@@ -0,0 +1,221 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// presencePrefix prefixes the methods telling a source field is set, e.g.
+// HasEmail on protobuf messages, presenceNone disables presence checks.
+const (
+	presencePrefix = "Has"
+	presenceNone   = "none"
+)
+
+// structMethods returns the methods declared on the structure, true for the
+// predicates taking nothing and returning a bool.
+func structMethods(meta *structMeta) map[string]bool {
+	if len(meta.fileLocation) == 0 {
+		return nil
+	}
+	packages, err := parser.ParseDir(token.NewFileSet(), filepath.Dir(meta.fileLocation), func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil
+	}
+	methods := make(map[string]bool)
+	for _, p := range packages {
+		for _, f := range p.Files {
+			for _, decl := range f.Decls {
+				funcDecl, ok := decl.(*ast.FuncDecl)
+				if !ok || funcDecl.Recv == nil || len(funcDecl.Recv.List) != 1 || receiverName(funcDecl.Recv.List[0].Type) != meta.name {
+					continue
+				}
+				results := funcDecl.Type.Results
+				predicate := len(funcDecl.Type.Params.List) == 0 && results != nil && len(results.List) == 1 &&
+					len(results.List[0].Names) <= 1 && typeStrValue(results.List[0].Type) == "bool"
+				methods[funcDecl.Name.Name] = predicate
+			}
+		}
+	}
+	return methods
+}
+
+// receiverName returns the name of the receiver type, pointer and type
+// parameters aside.
+func receiverName(typeExpr ast.Expr) string {
+	if starExpr, ok := typeExpr.(*ast.StarExpr); ok {
+		typeExpr = starExpr.X
+	}
+	switch t := typeExpr.(type) {
+	case *ast.IndexExpr:
+		typeExpr = t.X
+	case *ast.IndexListExpr:
+		typeExpr = t.X
+	}
+	if ident, ok := typeExpr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// presenceGuard returns the call of the presence method of the source
+// field, empty when the source has none.
+func presenceGuard(presence string, srcStruct src, srcFieldName string) string {
+	switch presence {
+	case "":
+		presence = presencePrefix
+	case presenceNone:
+		return ""
+	}
+	if !srcStruct.Methods[presence+srcFieldName] {
+		return ""
+	}
+	return fmt.Sprintf("%s.%s%s()", srcStruct.Alias, presence, srcFieldName)
+}
+
+// joinGuards returns the condition all the guards are met.
+func joinGuards(guards ...string) string {
+	var conditions []string
+	for _, guard := range guards {
+		if len(guard) != 0 {
+			conditions = append(conditions, guard)
+		}
+	}
+	return strings.Join(conditions, " && ")
+}
+
+// conditionGuard checks the condition of the destination field against the
+// sources and returns the guard of the rule mapping the field from the
+// source alias. The sources the condition refers to other than that one are
+// checked for nil first when the mapper takes pointers.
+func conditionGuard(mapperConfig mapperConfig, srcList []src, imports []importPackage, srcAlias, condition string) (string, error) {
+	expr, err := parser.ParseExpr(condition)
+	if err != nil {
+		return "", fmt.Errorf("condition %s: %w", condition, err)
+	}
+	names := map[string]bool{contextArg: mapperConfig.Context}
+	for _, param := range mapperConfig.Params {
+		names[param.Name] = true
+	}
+	for _, importPackage := range imports {
+		if len(importPackage.Alias) == 0 {
+			names[getPackageAlias(importPackage.Path)] = true
+		}
+		names[importPackage.Alias] = true
+	}
+	var usedAliases []string
+	var inspect func(node ast.Node) bool
+	inspect = func(node ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		switch node := node.(type) {
+		case *ast.SelectorExpr:
+			ident, ok := node.X.(*ast.Ident)
+			if !ok {
+				ast.Inspect(node.X, inspect)
+				return false
+			}
+			if srcStruct := searchSrc(srcList, ident.Name); srcStruct != nil {
+				if !containsString(usedAliases, ident.Name) {
+					usedAliases = append(usedAliases, ident.Name)
+				}
+				if _, method := srcStruct.Methods[node.Sel.Name]; !method && searchField(srcStruct.Fields, node.Sel.Name) == nil {
+					err = fmt.Errorf("condition %s: %s has no field or method %s", condition, srcStruct.ShortPath, node.Sel.Name)
+				}
+				return false
+			}
+			if !names[ident.Name] && len(importPackageAliasMap[ident.Name]) == 0 {
+				err = fmt.Errorf("condition %s: unknown name %s", condition, ident.Name)
+			}
+			return false
+		case *ast.KeyValueExpr:
+			if _, ok := node.Key.(*ast.Ident); ok {
+				ast.Inspect(node.Value, inspect)
+				return false
+			}
+		case *ast.Ident:
+			switch {
+			case searchSrc(srcList, node.Name) != nil:
+				if !containsString(usedAliases, node.Name) {
+					usedAliases = append(usedAliases, node.Name)
+				}
+			case !names[node.Name] && !predeclaredTypes[node.Name] && !builtinNames[node.Name]:
+				err = fmt.Errorf("condition %s: unknown name %s", condition, node.Name)
+			}
+		}
+		return true
+	}
+	ast.Inspect(expr, inspect)
+	if err != nil {
+		return "", err
+	}
+	if err := checkCondition(srcList, expr, condition); err != nil {
+		return "", err
+	}
+	var guards []string
+	if !mapperConfig.InputValue() {
+		for _, alias := range usedAliases {
+			if alias != srcAlias {
+				guards = append(guards, fmt.Sprintf("%s != nil", alias))
+			}
+		}
+	}
+	if binaryExpr, ok := expr.(*ast.BinaryExpr); ok && binaryExpr.Op == token.LOR {
+		condition = "(" + condition + ")"
+	}
+	return joinGuards(append(guards, condition)...), nil
+}
+
+// checkCondition checks the source fields the condition tests on their own
+// are booleans.
+func checkCondition(srcList []src, expr ast.Expr, condition string) error {
+	switch node := expr.(type) {
+	case *ast.ParenExpr:
+		return checkCondition(srcList, node.X, condition)
+	case *ast.UnaryExpr:
+		if node.Op == token.NOT {
+			return checkCondition(srcList, node.X, condition)
+		}
+	case *ast.BinaryExpr:
+		if node.Op == token.LAND || node.Op == token.LOR {
+			if err := checkCondition(srcList, node.X, condition); err != nil {
+				return err
+			}
+			return checkCondition(srcList, node.Y, condition)
+		}
+	case *ast.SelectorExpr:
+		ident, ok := node.X.(*ast.Ident)
+		if !ok {
+			return nil
+		}
+		srcStruct := searchSrc(srcList, ident.Name)
+		if srcStruct == nil {
+			return nil
+		}
+		if srcField := searchField(srcStruct.Fields, node.Sel.Name); srcField != nil && srcField.Underlying != "bool" {
+			return fmt.Errorf("condition %s: %s.%s of type %s isn't a bool", condition, ident.Name, node.Sel.Name, srcField.TypeStr)
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
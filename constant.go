@@ -0,0 +1,322 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// valueRules returns the rules setting the constants and the defaults of
+// the destination fields. Defaults are guarded by the source fields the
+// rules map into the destination field being missing or zero.
+func valueRules(dir string, mapperConfig mapperConfig, srcList []src, dst src, fieldMappingRuleMap map[string][]fieldMappingRule, imports []importPackage, options castOptions) (constants, defaults []fieldMappingRule, err error) {
+	for _, dstFieldName := range sortedKeys(mapperConfig.Constants) {
+		rule, err := valueRule(dir, mapperConfig, dst, dstFieldName, mapperConfig.Constants[dstFieldName], imports, options)
+		if err != nil {
+			return nil, nil, err
+		}
+		constants = append(constants, rule)
+	}
+	for _, dstFieldName := range sortedKeys(mapperConfig.Defaults) {
+		rule, err := valueRule(dir, mapperConfig, dst, dstFieldName, mapperConfig.Defaults[dstFieldName], imports, options)
+		if err != nil {
+			return nil, nil, err
+		}
+		guard, ok := defaultGuard(mapperConfig, srcList, fieldMappingRuleMap[dstFieldName])
+		if !ok {
+			// The source field can't be told zero, the default is set
+			// when the destination field still is.
+			guard, err = zeroStr(fmt.Sprintf("%s.%s", dst.Alias, dstFieldName), *searchField(dst.Fields, dstFieldName))
+			if err != nil {
+				return nil, nil, fmt.Errorf("mapper %s: field %s: %w", mapperConfig.MapperName(), dstFieldName, err)
+			}
+		}
+		rule.Guard = guard
+		defaults = append(defaults, rule)
+	}
+	return constants, defaults, nil
+}
+
+func valueRule(dir string, mapperConfig mapperConfig, dst src, dstFieldName string, value interface{}, imports []importPackage, options castOptions) (fieldMappingRule, error) {
+	rule := fieldMappingRule{DstFieldName: dstFieldName, Casted: true}
+	dstField := searchField(dst.Fields, dstFieldName)
+	if dstField == nil {
+		return rule, fmt.Errorf("mapper %s: field %s not found in %s", mapperConfig.MapperName(), dstFieldName, dst.ShortPath)
+	}
+	var err error
+	rule.CastStr, err = valueExpr(dir, value, *dstField, imports, options)
+	if err != nil {
+		return rule, fmt.Errorf("mapper %s: field %s: %w", mapperConfig.MapperName(), dstFieldName, err)
+	}
+	return rule, nil
+}
+
+// defaultGuard returns the condition the sources leave the destination
+// field unset: the source fields the rules map into it are zero or their
+// sources are nil. It is empty when no source provides the field, ok is
+// false when a rule isn't mapped from a source field of known zero value.
+func defaultGuard(mapperConfig mapperConfig, srcList []src, rules []fieldMappingRule) (string, bool) {
+	var guards []string
+	for _, rule := range rules {
+		if !rule.Casted {
+			continue
+		}
+		srcStruct := searchSrc(srcList, rule.SrcAlias)
+		if srcStruct == nil || len(rule.SrcFieldName) == 0 {
+			return "", false
+		}
+		srcRow := fmt.Sprintf("%s.%s", rule.SrcAlias, rule.SrcFieldName)
+		// Relations mapping an expression of the field aren't told zero by
+		// the field.
+		if rule.SrcFieldName != rule.DstFieldName && rule.CastStr != srcRow {
+			return "", false
+		}
+		guard, err := zeroStr(srcRow, *searchField(srcStruct.Fields, rule.SrcFieldName))
+		if err != nil {
+			return "", false
+		}
+		if !mapperConfig.InputValue() {
+			guard = fmt.Sprintf("%s == nil || %s", rule.SrcAlias, guard)
+		}
+		guards = append(guards, guard)
+	}
+	if len(guards) > 1 {
+		for i := range guards {
+			guards[i] = "(" + guards[i] + ")"
+		}
+	}
+	return joinGuards(guards...), true
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// valueExpr returns the Go expression of the value set to the destination
+// field, checked against the field type. Strings are string literals, Go
+// expressions are given by the expr key, e.g. {expr: model.StatusActive}.
+func valueExpr(dir string, value interface{}, dstField field, imports []importPackage, options castOptions) (string, error) {
+	elemType, underlying := strings.TrimPrefix(dstField.TypeStr, "*"), strings.TrimPrefix(dstField.Underlying, "*")
+	var valueStr string
+	switch value := value.(type) {
+	case bool:
+		if underlying != "bool" {
+			return "", fmt.Errorf("bool value %v assigned to %s", value, dstField.TypeStr)
+		}
+		valueStr = strconv.FormatBool(value)
+	case int:
+		if _, numeric := numericTypeBits[underlying]; !numeric || (value < 0 && isUnsigned(underlying)) {
+			return "", fmt.Errorf("integer value %d assigned to %s", value, dstField.TypeStr)
+		}
+		valueStr = strconv.Itoa(value)
+	case float64:
+		if !isFloat(underlying) {
+			return "", fmt.Errorf("float value %v assigned to %s", value, dstField.TypeStr)
+		}
+		valueStr = strconv.FormatFloat(value, 'g', -1, 64)
+	case string:
+		if underlying != "string" {
+			return "", fmt.Errorf("string value %q assigned to %s", value, dstField.TypeStr)
+		}
+		valueStr = strconv.Quote(value)
+	case map[interface{}]interface{}:
+		exprStr, ok := value["expr"].(string)
+		if !ok || len(value) != 1 {
+			return "", fmt.Errorf("value %v isn't an expression, expr key expected", value)
+		}
+		expr, err := parser.ParseExpr(exprStr)
+		if err != nil {
+			return "", fmt.Errorf("expression %s: %w", exprStr, err)
+		}
+		if refersUnqualified(expr) {
+			return "", fmt.Errorf("expression %s refers to names of no package", exprStr)
+		}
+		if err := checkExpr(dir, expr, elemType, underlying, imports); err != nil {
+			return "", fmt.Errorf("value %s: %w", exprStr, err)
+		}
+		valueStr = exprStr
+	default:
+		return "", fmt.Errorf("value %v of type %T unsupported", value, value)
+	}
+	switch {
+	case !strings.HasPrefix(dstField.TypeStr, "*"):
+	case untypedDefaults[elemType]:
+		return ptrStr(elemType, valueStr, options), nil
+	default:
+		return ptrStr(elemType, fmt.Sprintf("%s(%s)", elemType, valueStr), options), nil
+	}
+	return valueStr, nil
+}
+
+// untypedDefaults are the types untyped constants default to, values of
+// other types are converted before taking their address.
+var untypedDefaults = map[string]bool{"bool": true, "string": true, "int": true, "float64": true}
+
+// builtinNames are the predeclared names other than types.
+var builtinNames = map[string]bool{
+	"true": true, "false": true, "nil": true, "iota": true,
+	"append": true, "cap": true, "complex": true, "imag": true, "len": true,
+	"make": true, "max": true, "min": true, "new": true, "real": true,
+}
+
+// refersUnqualified reports whether the expression refers to a name
+// neither predeclared nor qualified by a package, the names it selects and
+// the keys of its composite literals aside.
+func refersUnqualified(expr ast.Expr) bool {
+	var found bool
+	var inspect func(node ast.Node) bool
+	inspect = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.SelectorExpr:
+			if _, ok := node.X.(*ast.Ident); ok {
+				return false
+			}
+			ast.Inspect(node.X, inspect)
+			return false
+		case *ast.KeyValueExpr:
+			if _, ok := node.Key.(*ast.Ident); ok {
+				ast.Inspect(node.Value, inspect)
+				return false
+			}
+		case *ast.Ident:
+			found = found || (!predeclaredTypes[node.Name] && !builtinNames[node.Name])
+		}
+		return !found
+	}
+	ast.Inspect(expr, inspect)
+	return found
+}
+
+// checkExpr checks the literals suit the underlying type and the packages
+// the expression refers to are imported, the constants and variables it
+// selects being of the destination type when they are typed.
+func checkExpr(dir string, expr ast.Expr, dstType, underlying string, imports []importPackage) error {
+	if lit, ok := expr.(*ast.BasicLit); ok {
+		_, numeric := numericTypeBits[underlying]
+		switch {
+		case lit.Kind == token.STRING && underlying == "string",
+			lit.Kind == token.INT && numeric,
+			lit.Kind == token.FLOAT && isFloat(underlying),
+			lit.Kind == token.CHAR && numeric:
+			return nil
+		}
+		return fmt.Errorf("literal assigned to %s", dstType)
+	}
+	var err error
+	ast.Inspect(expr, func(node ast.Node) bool {
+		selectorExpr, ok := node.(*ast.SelectorExpr)
+		if !ok || err != nil {
+			return err == nil
+		}
+		packageIdent, ok := selectorExpr.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		importPath := importPackageAliasMap[packageIdent.Name]
+		for _, importPackage := range imports {
+			if importPackage.Alias == packageIdent.Name || (len(importPackage.Alias) == 0 && getPackageAlias(importPackage.Path) == packageIdent.Name) {
+				importPath = importPackage.Path
+			}
+		}
+		if len(importPath) == 0 {
+			err = fmt.Errorf("package %s not imported", packageIdent.Name)
+			return false
+		}
+		if selectorExpr != expr {
+			return false
+		}
+		packageDir, dirErr := resolveImportDir(dir, importPath)
+		if dirErr != nil {
+			return false
+		}
+		valueType, declared := declaredValueType(packageDir, selectorExpr.Sel.Name)
+		switch {
+		case !declared:
+			err = fmt.Errorf("%s not declared in package %s", selectorExpr.Sel.Name, importPath)
+		case len(valueType) != 0 && valueType != dstType && qualifyType(packageIdent.Name, valueType) != dstType:
+			err = fmt.Errorf("%s of type %s assigned to %s", selectorExpr.Sel.Name, qualifyType(packageIdent.Name, valueType), dstType)
+		}
+		return false
+	})
+	return err
+}
+
+// declaredValueType finds the constant or the variable declared in the
+// package directory and returns its type, empty when it is untyped.
+func declaredValueType(packageDir, name string) (string, bool) {
+	packages, err := parser.ParseDir(token.NewFileSet(), packageDir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return "", false
+	}
+	for _, p := range packages {
+		for _, f := range p.Files {
+			for _, decl := range f.Decls {
+				genDecl, ok := decl.(*ast.GenDecl)
+				if !ok || (genDecl.Tok != token.CONST && genDecl.Tok != token.VAR) {
+					continue
+				}
+				// Constants of a group without a type nor values repeat
+				// the type of the previous ones.
+				var groupType ast.Expr
+				for _, spec := range genDecl.Specs {
+					valueSpec := spec.(*ast.ValueSpec)
+					if valueSpec.Type != nil || len(valueSpec.Values) != 0 {
+						groupType = valueSpec.Type
+					}
+					for _, ident := range valueSpec.Names {
+						if ident.Name != name {
+							continue
+						}
+						if groupType == nil {
+							return "", true
+						}
+						return typeStrValue(groupType), true
+					}
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// qualifyType qualifies the type declared in the package with its alias.
+func qualifyType(packageAlias, typeStr string) string {
+	if predeclaredTypes[typeStr] || strings.Contains(typeStr, ".") {
+		return typeStr
+	}
+	return packageAlias + "." + typeStr
+}
+
+// zeroStr returns the condition the field is zero.
+func zeroStr(row string, f field) (string, error) {
+	switch {
+	case strings.HasPrefix(f.TypeStr, "*"):
+		return fmt.Sprintf("%s == nil", row), nil
+	case strings.HasPrefix(f.Underlying, "[]"), strings.HasPrefix(f.Underlying, "map["):
+		return fmt.Sprintf("len(%s) == 0", row), nil
+	case f.Underlying == "string":
+		return fmt.Sprintf("%s == \"\"", row), nil
+	case f.Underlying == "bool":
+		return "!" + row, nil
+	}
+	if _, numeric := numericTypeBits[f.Underlying]; numeric {
+		return fmt.Sprintf("%s == 0", row), nil
+	}
+	return "", fmt.Errorf("zero value of %s can't be told", f.TypeStr)
+}
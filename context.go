@@ -0,0 +1,65 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+)
+
+// contextArg is the parameter of the context-aware mappers, relations of
+// those mappers may refer to it.
+const contextArg = "ctx"
+
+// funcConfig is a custom converter of the fields of the Src type into the
+// Dst type. Context tells the function takes the context of the mapper
+// first, Errors tells it also returns an error.
+type funcConfig struct {
+	Src     string `yaml:"src"`
+	Dst     string `yaml:"dst"`
+	Func    string `yaml:"func"`
+	Context bool   `yaml:"context"`
+	Errors  bool   `yaml:"errors"`
+}
+
+// contextParams returns the context parameter of the mapper signatures,
+// empty unless the mapper is context-aware.
+func contextParams(mapperConfig mapperConfig) string {
+	if !mapperConfig.Context {
+		return ""
+	}
+	return fmt.Sprintf("%s %s.Context", contextArg, useImport("context", "context"))
+}
+
+// castFuncField converts the field with the custom converter of its types,
+// converters taking the context are only called by context-aware mappers
+// and converters returning an error by error returning ones.
+func castFuncField(srcAlias string, srcField, dstField field, options castOptions) (castStr string, checked bool, ok bool) {
+	for _, funcConfig := range options.funcs {
+		if funcConfig.Src != srcField.TypeStr || funcConfig.Dst != dstField.TypeStr {
+			continue
+		}
+		if (funcConfig.Context && !options.context) || (funcConfig.Errors && !options.returnsError) {
+			continue
+		}
+		srcRow := fmt.Sprintf("%s.%s", srcAlias, srcField.Name)
+		if funcConfig.Context {
+			return fmt.Sprintf("%s(%s, %s)", funcConfig.Func, contextArg, srcRow), funcConfig.Errors, true
+		}
+		return fmt.Sprintf("%s(%s)", funcConfig.Func, srcRow), funcConfig.Errors, true
+	}
+	return "", false, false
+}
+
+// isContextType reports whether the type is context.Context of the file
+// imports.
+func isContextType(typeExpr ast.Expr, imports map[string]string) bool {
+	selectorExpr, ok := typeExpr.(*ast.SelectorExpr)
+	if !ok || selectorExpr.Sel.Name != "Context" {
+		return false
+	}
+	packageIdent, ok := selectorExpr.X.(*ast.Ident)
+	return ok && imports[packageIdent.Name] == "context"
+}
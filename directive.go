@@ -0,0 +1,340 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const directivePrefix = "//mapstruct:"
+
+// directive is a "//mapstruct:key value" or "//mapstruct:key=value" comment.
+type directive struct {
+	Key   string
+	Value string
+}
+
+// parseDirectives returns the directives of the comment group.
+func parseDirectives(doc *ast.CommentGroup) []directive {
+	if doc == nil {
+		return nil
+	}
+	var directives []directive
+	for _, comment := range doc.List {
+		if !strings.HasPrefix(comment.Text, directivePrefix) {
+			continue
+		}
+		text := strings.TrimSpace(strings.TrimPrefix(comment.Text, directivePrefix))
+		key, value := text, ""
+		if i := strings.IndexAny(text, " ="); i >= 0 {
+			key, value = text[:i], strings.TrimSpace(text[i+1:])
+		}
+		directives = append(directives, directive{Key: key, Value: value})
+	}
+	return directives
+}
+
+// applyMapperDirective sets the mapper option the directive stands for.
+func applyMapperDirective(mapperConfig *mapperConfig, directive directive) error {
+	switch directive.Key {
+	case "checked":
+		mapperConfig.Checked = true
+	case "errors":
+		mapperConfig.Errors = true
+	case "convert_named_types":
+		mapperConfig.ConvertNamedTypes = true
+	case "merge":
+		mapperConfig.Merge = directive.Value
+	case "list":
+		mapperConfig.List = directive.Value
+	case "join_key":
+		mapperConfig.JoinKey = directive.Value
+	case "relation":
+		if strings.Count(directive.Value, ":") != 1 {
+			return fmt.Errorf("%srelation %s: Field: expr expected", directivePrefix, directive.Value)
+		}
+		mapperConfig.Relations = append(mapperConfig.Relations, directive.Value)
+	case "ignore":
+		mapperConfig.Ignore = append(mapperConfig.Ignore, strings.Fields(directive.Value)...)
+	case "param":
+		nameType := strings.Fields(directive.Value)
+		if len(nameType) != 2 {
+			return fmt.Errorf("%sparam: name and type expected", directivePrefix)
+		}
+		mapperConfig.Params = append(mapperConfig.Params, paramConfig{Name: nameType[0], Type: nameType[1]})
+	case "before":
+		mapperConfig.Before = directive.Value
+	case "after":
+		mapperConfig.After = directive.Value
+	case "validate":
+		mapperConfig.Validate = true
+	case "validator":
+		mapperConfig.Validator = directive.Value
+	case "context":
+		mapperConfig.Context = true
+	case "map_key":
+		mapperConfig.MapKey = directive.Value
+	case "time_format":
+		mapperConfig.TimeFormat = directive.Value
+	case "uuid_strings":
+		mapperConfig.UUIDStrings = true
+	case "cycles":
+		mapperConfig.Cycles = true
+	case "max_depth":
+		maxDepth, err := strconv.Atoi(directive.Value)
+		if err != nil {
+			return fmt.Errorf("%smax_depth: %w", directivePrefix, err)
+		}
+		mapperConfig.MaxDepth = maxDepth
+	default:
+		return fmt.Errorf("unknown directive %s%s", directivePrefix, directive.Key)
+	}
+	return nil
+}
+
+// packageMappers gathers the mappers declared on the structures of the
+// package directory by "//mapstruct:map Dst=pb.User" directives, the
+// structure being the source, or "//mapstruct:map Src=pb.User", the
+// structure being the destination, map standing for map[string]any.
+// Other directives of the structure apply to its mappers, its fields take
+// "//mapstruct:ignore", "//mapstruct:from=expr" on destinations and
+// "//mapstruct:to=Field" on sources.
+func packageMappers(dir, packageDir string) ([]mapperConfig, error) {
+	fileNames, err := filepath.Glob(filepath.Join(dir, packageDir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	var mappers []mapperConfig
+	for _, fileName := range fileNames {
+		if strings.HasSuffix(fileName, "_test.go") {
+			continue
+		}
+		fileAST, err := parser.ParseFile(token.NewFileSet(), fileName, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		resolver := typePathResolver{
+			dir:     dir,
+			fileDir: filepath.Dir(fileName),
+			imports: fileImports(fileAST),
+		}
+		for _, decl := range fileAST.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				structType, ok := typeSpec.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				directives := parseDirectives(typeSpec.Doc)
+				if len(genDecl.Specs) == 1 {
+					directives = append(parseDirectives(genDecl.Doc), directives...)
+				}
+				structPath, err := configPath(dir, fileName, typeSpec.Name.Name)
+				if err != nil {
+					return nil, err
+				}
+				structMappers, err := structMappers(structPath, structType, directives, resolver)
+				if err != nil {
+					return nil, fmt.Errorf("structure %s: %w", typeSpec.Name.Name, err)
+				}
+				mappers = append(mappers, structMappers...)
+			}
+		}
+	}
+	return mappers, nil
+}
+
+// structMappers builds the mappers the directives of the structure declare.
+func structMappers(structPath string, structType *ast.StructType, directives []directive, resolver typePathResolver) ([]mapperConfig, error) {
+	var mappers []mapperConfig
+	var options []directive
+	var asSource, asDestination bool
+	for _, directive := range directives {
+		if directive.Key != "map" {
+			options = append(options, directive)
+			continue
+		}
+		var mapperConfig mapperConfig
+		var isSource bool
+		for _, token := range strings.Fields(directive.Value) {
+			i := strings.Index(token, "=")
+			if i <= 0 {
+				return nil, fmt.Errorf("%smap %s: key=value expected", directivePrefix, token)
+			}
+			key, value := token[:i], token[i+1:]
+			if key == "Alias" {
+				mapperConfig.Alias = value
+				continue
+			}
+			if key != "Dst" && key != "Src" {
+				return nil, fmt.Errorf("%smap: unknown key %s", directivePrefix, key)
+			}
+			path := dynamicMapPath
+			if value != dynamicMapPath {
+				typeExpr, err := parser.ParseExpr(value)
+				if err != nil {
+					return nil, fmt.Errorf("%smap %s: %w", directivePrefix, token, err)
+				}
+				path, err = resolver.typePath(typeExpr)
+				if err != nil {
+					return nil, fmt.Errorf("%smap %s: %w", directivePrefix, token, err)
+				}
+			}
+			isSource = key == "Dst"
+			mapperConfig.Destination = sourceConfig{Alias: "dst", Path: structPath}
+			mapperConfig.Sources = []sourceConfig{{Alias: "src", Path: path}}
+			if isSource {
+				mapperConfig.Destination.Path, mapperConfig.Sources[0].Path = path, structPath
+			}
+		}
+		if len(mapperConfig.Sources) == 0 {
+			return nil, fmt.Errorf("%smap: Dst or Src expected", directivePrefix)
+		}
+		if err := applyFieldDirectives(&mapperConfig, structType, isSource); err != nil {
+			return nil, err
+		}
+		asSource, asDestination = asSource || isSource, asDestination || !isSource
+		mappers = append(mappers, mapperConfig)
+	}
+	if err := checkFieldDirectives(structType, asSource, asDestination); err != nil {
+		return nil, err
+	}
+	for i := range mappers {
+		for _, directive := range options {
+			if err := applyMapperDirective(&mappers[i], directive); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return mappers, nil
+}
+
+// applyFieldDirectives turns the directives of the structure fields into
+// ignored fields and relations of the mapper, ignore and from apply when
+// the structure is the destination and to when it's the source.
+func applyFieldDirectives(mapperConfig *mapperConfig, structType *ast.StructType, isSource bool) error {
+	for _, f := range structType.Fields.List {
+		if len(f.Names) == 0 {
+			continue
+		}
+		fieldName := f.Names[0].Name
+		for _, directive := range parseDirectives(f.Doc) {
+			switch directive.Key {
+			case "ignore":
+				if !isSource {
+					mapperConfig.Ignore = append(mapperConfig.Ignore, fieldName)
+				}
+			case "from":
+				if len(directive.Value) == 0 || strings.Contains(directive.Value, ":") {
+					return fmt.Errorf("field %s: %sfrom=%s: expression without : expected", fieldName, directivePrefix, directive.Value)
+				}
+				if !isSource {
+					mapperConfig.Relations = append(mapperConfig.Relations, fmt.Sprintf("%s: %s", fieldName, directive.Value))
+				}
+			case "to":
+				if isSource {
+					mapperConfig.Relations = append(mapperConfig.Relations, fmt.Sprintf("%s: src.%s", directive.Value, fieldName))
+				}
+			default:
+				return fmt.Errorf("field %s: unknown directive %s%s", fieldName, directivePrefix, directive.Key)
+			}
+		}
+	}
+	return nil
+}
+
+// checkFieldDirectives returns an error for the field directive no mapper
+// of the structure applies, ignore and from on a structure that is only a
+// source and to on a structure that is only a destination.
+func checkFieldDirectives(structType *ast.StructType, asSource, asDestination bool) error {
+	for _, f := range structType.Fields.List {
+		if len(f.Names) == 0 {
+			continue
+		}
+		for _, directive := range parseDirectives(f.Doc) {
+			switch {
+			case (directive.Key == "ignore" || directive.Key == "from") && !asDestination:
+				return fmt.Errorf("field %s: %s%s applies to destination structures", f.Names[0].Name, directivePrefix, directive.Key)
+			case directive.Key == "to" && !asSource:
+				return fmt.Errorf("field %s: %s%s applies to source structures", f.Names[0].Name, directivePrefix, directive.Key)
+			}
+		}
+	}
+	return nil
+}
+
+// mergeMappers adds the mappers declared by directives to the configured
+// ones. A configured mapper of the same name takes the directives of the
+// declared one, its own options taking precedence. Declared mappers of the
+// same name are an error.
+func mergeMappers(mappers, declaredMappers []mapperConfig) ([]mapperConfig, error) {
+	for i, declared := range declaredMappers {
+		for _, other := range declaredMappers[:i] {
+			if other.MapperName() == declared.MapperName() {
+				return nil, fmt.Errorf("mapper %s declared twice, set the Alias of %smap", declared.MapperName(), directivePrefix)
+			}
+		}
+		var configured *mapperConfig
+		for i := range mappers {
+			if mappers[i].MapperName() == declared.MapperName() {
+				configured = &mappers[i]
+			}
+		}
+		if configured == nil {
+			mappers = append(mappers, declared)
+			continue
+		}
+		configured.Relations = append(declared.Relations, configured.Relations...)
+		configured.Ignore = append(declared.Ignore, configured.Ignore...)
+		configured.Checked = configured.Checked || declared.Checked
+		configured.ConvertNamedTypes = configured.ConvertNamedTypes || declared.ConvertNamedTypes
+		configured.Errors = configured.Errors || declared.Errors
+		configured.Cycles = configured.Cycles || declared.Cycles
+		configured.Context = configured.Context || declared.Context
+		configured.Validate = configured.Validate || declared.Validate
+		configured.UUIDStrings = configured.UUIDStrings || declared.UUIDStrings
+		if len(configured.Validator) == 0 {
+			configured.Validator = declared.Validator
+		}
+		if len(configured.Before) == 0 {
+			configured.Before = declared.Before
+		}
+		if len(configured.After) == 0 {
+			configured.After = declared.After
+		}
+		if len(configured.MapKey) == 0 {
+			configured.MapKey = declared.MapKey
+		}
+		if len(configured.TimeFormat) == 0 {
+			configured.TimeFormat = declared.TimeFormat
+		}
+		if len(configured.Params) == 0 {
+			configured.Params = declared.Params
+		}
+		if configured.MaxDepth == 0 {
+			configured.MaxDepth = declared.MaxDepth
+		}
+		if len(configured.Merge) == 0 {
+			configured.Merge = declared.Merge
+		}
+		if len(configured.List) == 0 {
+			configured.List = declared.List
+		}
+		if len(configured.JoinKey) == 0 {
+			configured.JoinKey = declared.JoinKey
+		}
+	}
+	return mappers, nil
+}
@@ -0,0 +1,301 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// dynamicMapPath is the path of the map[string]any sources and destinations
+// the structure fields are mapped from and into by key, times are formatted
+// with the defaultTimeFormat layout unless configured otherwise.
+const (
+	dynamicMapPath    = "map"
+	dynamicMapType    = "map[string]any"
+	defaultTimeFormat = "time.RFC3339"
+)
+
+// MapSource reports whether the mapper maps a map[string]any into a
+// structure.
+func (mc mapperConfig) MapSource() bool {
+	for _, source := range mc.Sources {
+		if source.Path == dynamicMapPath {
+			return true
+		}
+	}
+	return false
+}
+
+// MapDestination reports whether the mapper maps a structure into a
+// map[string]any.
+func (mc mapperConfig) MapDestination() bool {
+	return mc.Destination.Path == dynamicMapPath
+}
+
+// validateDynamicMap checks the map mapper maps a single structure into a
+// map or a map into a structure. Maps are passed by value and the options
+// relying on the fields of the map side are refused.
+func validateDynamicMap(mapperConfig mapperConfig, inlineHelpers bool) error {
+	if !mapperConfig.MapSource() && !mapperConfig.MapDestination() {
+		return nil
+	}
+	var unsupported string
+	switch {
+	case len(mapperConfig.Sources) != 1:
+		return fmt.Errorf("mapper %s: map mapping requires a single source", mapperConfig.MapperName())
+	case mapperConfig.MapSource() && mapperConfig.MapDestination():
+		return fmt.Errorf("mapper %s: map mapping requires a structure", mapperConfig.MapperName())
+	case (mapperConfig.MapSource() && (mapperConfig.Input == signaturePointer || mapperConfig.ListInput == signaturePointer)) ||
+		(mapperConfig.MapDestination() && (mapperConfig.Output == signaturePointer || mapperConfig.ListOutput == signaturePointer)):
+		return fmt.Errorf("mapper %s: maps are passed by value", mapperConfig.MapperName())
+	case mapperConfig.MapSource() && inlineHelpers:
+		return fmt.Errorf("mapper %s: mapping maps into structures requires runtime helpers", mapperConfig.MapperName())
+	case mapperConfig.MapSource() && len(mapperConfig.Conditions) != 0:
+		unsupported = "conditions"
+	case mapperConfig.MapSource() && mapperConfig.Index != nil:
+		unsupported = "index"
+	case mapperConfig.MapSource() && mapperConfig.List == listJoin:
+		unsupported = "join list"
+	case mapperConfig.MapDestination() && len(mapperConfig.Defaults) != 0:
+		unsupported = "defaults"
+	}
+	if len(unsupported) != 0 {
+		return fmt.Errorf("mapper %s: %s unsupported by map mapping", mapperConfig.MapperName(), unsupported)
+	}
+	return nil
+}
+
+// fieldKey returns the map key of the structure field, the name its tag
+// gives it or its name. skip tells the tag leaves the field out, omitEmpty
+// that its zero values are.
+func fieldKey(tagName string, structField field) (key string, omitEmpty, skip bool) {
+	key = structField.Name
+	if len(tagName) == 0 {
+		return key, false, false
+	}
+	tag, err := strconv.Unquote(structField.Tag)
+	if err != nil {
+		return key, false, false
+	}
+	value, ok := reflect.StructTag(tag).Lookup(tagName)
+	if !ok {
+		return key, false, false
+	}
+	if value == "-" {
+		return "", false, true
+	}
+	name, options, _ := strings.Cut(value, ",")
+	if len(name) != 0 {
+		key = name
+	}
+	return key, containsString(strings.Split(options, ","), "omitempty"), false
+}
+
+// dynamicFields returns the fields of the map side of the mapper, one per
+// structure field keyed by fieldKey. Map sources hold values of any type.
+func dynamicFields(mapperConfig mapperConfig, structFields []field) []field {
+	fields := make([]field, 0, len(structFields))
+	for _, structField := range structFields {
+		key, _, skip := fieldKey(mapperConfig.MapKey, structField)
+		if skip {
+			continue
+		}
+		dynamicField := structField
+		dynamicField.Key = key
+		if mapperConfig.MapSource() {
+			dynamicField.Ptr, dynamicField.TypeStr, dynamicField.Underlying = false, "any", "any"
+		}
+		fields = append(fields, dynamicField)
+	}
+	return fields
+}
+
+// timeLayout returns the expression of the layout times are formatted and
+// parsed with, a time package constant or a quoted layout.
+func timeLayout(mapperConfig mapperConfig) string {
+	switch {
+	case len(mapperConfig.TimeFormat) == 0:
+		useImport("time", "time")
+		return defaultTimeFormat
+	case strings.HasPrefix(mapperConfig.TimeFormat, "time."):
+		useImport("time", "time")
+		return mapperConfig.TimeFormat
+	}
+	return strconv.Quote(mapperConfig.TimeFormat)
+}
+
+// castMapField fills the rule mapping the structure field into its map key
+// or the value of the map key into the structure field. Times are
+// formatted, structures having a map mapper become nested maps and the
+// zero values the omitempty tag option leaves out, the nil pointers and the
+// missing keys are skipped. Map values are converted checking their type.
+func castMapField(rule fieldMappingRule, mapperConfig mapperConfig, srcAlias string, srcField, dstField field, options castOptions) fieldMappingRule {
+	if mapperConfig.MapDestination() {
+		srcRow := fmt.Sprintf("%s.%s", srcAlias, srcField.Name)
+		srcPtr := strings.HasPrefix(srcField.TypeStr, "*")
+		if _, omitEmpty, _ := fieldKey(mapperConfig.MapKey, srcField); omitEmpty || srcPtr {
+			rule.Guard = joinGuards(rule.Guard, nonZeroStr(srcRow, srcField))
+		}
+		rule.CastStr, rule.Casted = srcRow, true
+		switch dstType := mapValueType(srcField.TypeStr, options); {
+		case strings.TrimPrefix(srcField.TypeStr, "*") == "time.Time":
+			rule.CastStr = fmt.Sprintf("%s.Format(%s)", srcRow, timeLayout(mapperConfig))
+		case dstType != srcField.TypeStr:
+			rule.CastStr, rule.Checked, rule.CastAddr, rule.Casted = castNestedField(srcAlias, srcField, field{TypeStr: dstType}, options)
+		case srcPtr:
+			rule.CastStr = "*" + srcRow
+		}
+		return rule
+	}
+	srcRow := fmt.Sprintf("%s[%s]", srcAlias, strconv.Quote(srcField.Key))
+	rule.Guard = joinGuards(rule.Guard, srcRow+" != nil")
+	rule.CastStr = srcRow
+	mappingAlias := useImport("mapping", mappingPackage)
+	args := fmt.Sprintf("%s, %s", srcAlias, strconv.Quote(srcField.Key))
+	elemType := strings.TrimPrefix(dstField.TypeStr, "*")
+	dstPtr := elemType != dstField.TypeStr
+	if elemType == "time.Time" {
+		rule.CastStr = fmt.Sprintf("%s.TimeFromMap(%s, %s)", mappingAlias, args, timeLayout(mapperConfig))
+		rule.CastAddr, rule.Checked, rule.Casted = dstPtr, true, true
+		return rule
+	}
+	if mapper, ok := options.nestedMapper(dynamicMapType, elemType); ok && mapper.ReturnsError {
+		rule.CastStr = fmt.Sprintf("%s.NestedFromMap(%s, %s)", mappingAlias, args, mapper.mapFuncStr(elemType, mapper.OutputValue))
+		rule.CastAddr, rule.CastDeref = dstPtr && mapper.OutputValue, !dstPtr && !mapper.OutputValue
+		rule.Checked, rule.Casted = true, true
+		return rule
+	}
+	if dstElem := strings.TrimPrefix(dstField.TypeStr, "[]"); dstElem != dstField.TypeStr {
+		structType := strings.TrimPrefix(dstElem, "*")
+		if mapper, ok := options.nestedMapper(dynamicMapType, dstElem); ok && mapper.ReturnsError {
+			rule.CastStr = fmt.Sprintf("%s.NestedSliceFromMap(%s, %s)", mappingAlias, args, mapper.mapFuncStr(structType, structType == dstElem))
+			rule.Checked, rule.Casted = true, true
+			return rule
+		}
+	}
+	rule.CastStr = fmt.Sprintf("%s.FromMap[%s](%s)", mappingAlias, dstField.TypeStr, args)
+	rule.Checked, rule.Casted = true, true
+	return rule
+}
+
+// mapValueType replaces the structures having a map mapper in the type of
+// the structure field with map[string]any.
+func mapValueType(typeStr string, options castOptions) string {
+	nested := func(elemType string) bool {
+		_, ok := options.nestedMapper(elemType, dynamicMapType)
+		return ok
+	}
+	switch {
+	case strings.HasPrefix(typeStr, "[]"):
+		if nested(strings.TrimPrefix(typeStr, "[]")) {
+			return "[]" + dynamicMapType
+		}
+	case strings.HasPrefix(typeStr, "map["):
+		if key, value := splitMapType(typeStr); nested(value) {
+			return fmt.Sprintf("map[%s]%s", key, dynamicMapType)
+		}
+	case nested(typeStr):
+		return dynamicMapType
+	}
+	return typeStr
+}
+
+// mapFuncStr returns the function mapping nested maps into the structure
+// type, by value when outputValue is set and by pointer otherwise. It is a
+// closure when the mapper takes the context or extra parameters or returns
+// the structure in the other form, nil pointers becoming zero values.
+func (mapper nestedMapper) mapFuncStr(structType string, outputValue bool) string {
+	if !mapper.Context && len(mapper.Params) == 0 && mapper.OutputValue == outputValue {
+		return mapper.MapperFuncName
+	}
+	callStr := mapper.callStr(mapper.MapperFuncName, "m")
+	var body string
+	switch {
+	case mapper.OutputValue == outputValue:
+		body = "return " + callStr
+	case outputValue:
+		body = fmt.Sprintf("v, err := %s; return %s.Deref(v), err", callStr, useImport("mapping", mappingPackage))
+	default:
+		body = fmt.Sprintf("v, err := %s; return &v, err", callStr)
+	}
+	return fmt.Sprintf("func(m %s) (%s%s, error) { %s }", dynamicMapType, signaturePtr(outputValue), structType, body)
+}
+
+// dynamicHelperMappers returns the map mappers of the structures the map
+// mappers reach through their fields, transitively, unless a map mapper of
+// the structure in that direction is configured. The helpers key the
+// fields and format the times as the mapper reaching them first.
+func dynamicHelperMappers(dir string, mappers []mapperConfig) ([]mapperConfig, error) {
+	known := make(map[[2]string]bool, len(mappers))
+	names := make(map[string]bool, len(mappers))
+	type reached struct {
+		meta *structMeta
+		root mapperConfig
+	}
+	var queue []reached
+	for _, mapperConfig := range mappers {
+		names[mapperConfig.MapperName()] = true
+		if len(mapperConfig.Sources) != 1 || mapperConfig.MapSource() == mapperConfig.MapDestination() {
+			continue
+		}
+		structPath := mapperConfig.Sources[0].Path
+		if mapperConfig.MapSource() {
+			structPath = mapperConfig.Destination.Path
+		}
+		meta, err := parseStructure(dir, structPath)
+		if err != nil {
+			return nil, err
+		}
+		known[[2]string{shortPath(meta), dynamicMapDirection(mapperConfig)}] = true
+		queue = append(queue, reached{meta: meta, root: mapperConfig})
+	}
+
+	var helpers []mapperConfig
+	for len(queue) != 0 {
+		meta, root := queue[0].meta, queue[0].root
+		queue = queue[1:]
+		direction := dynamicMapDirection(root)
+		for _, path := range reachedStructures(dir, meta) {
+			nestedMeta, err := parseStructure(dir, path)
+			if err != nil || known[[2]string{shortPath(nestedMeta), direction}] {
+				continue
+			}
+			if ts, exist := nestedMeta.types[nestedMeta.name]; !exist || !isStructType(ts) {
+				continue
+			}
+			known[[2]string{shortPath(nestedMeta), direction}] = true
+			queue = append(queue, reached{meta: nestedMeta, root: root})
+
+			helper := mapperConfig{
+				Alias:         nestedMeta.name + direction,
+				MapKey:        root.MapKey,
+				TimeFormat:    root.TimeFormat,
+				dynamicHelper: true,
+				Destination:   sourceConfig{Alias: "dst", Path: dynamicMapPath},
+				Sources:       []sourceConfig{{Alias: "src", Path: path}},
+			}
+			if root.MapSource() {
+				helper.Destination.Path, helper.Sources[0].Path = path, dynamicMapPath
+			}
+			if names[helper.MapperName()] {
+				helper.Alias = strings.Title(getPackageAlias(nestedMeta.packagePath)) + helper.Alias
+			}
+			names[helper.MapperName()] = true
+			helpers = append(helpers, helper)
+		}
+	}
+	return helpers, nil
+}
+
+// dynamicMapDirection names the direction of the map mapper.
+func dynamicMapDirection(mapperConfig mapperConfig) string {
+	if mapperConfig.MapSource() {
+		return "FromMap"
+	}
+	return "ToMap"
+}
@@ -43,6 +43,7 @@ import (
 	{{if .Alias}}{{ .Alias }} {{end}}"{{ .Path }}"
 {{- end }}
 )
+{{- if .InlineHelpers }}
 {{- range .TypeToPtrList }}
 func {{ . }}Ptr(src {{ . }}) *{{ . }} {
 	return &src
@@ -51,6 +52,13 @@ func {{ . }}Ptr(src {{ . }}) *{{ . }} {
 {{- range .TypesCastList }}
 {{ $name := .Name }}
 {{- range .CastTypes }}
+func {{ $name }}ArrTo{{ . | ToTitle }}Arr(src []{{ $name }}) (dst []{{ . }}) {
+	dst = make([]{{ . }}, len(src))
+	for i := range src {
+		dst[i] = {{ . }}(src[i])
+	}
+	return dst
+}
 func {{ $name }}ArrTo{{ . | ToTitle }}PtrArr(src []{{ $name }}) (dst []*{{ . }}) {
 	dst = make([]*{{ . }}, len(src))
 	for i := range src {
@@ -61,61 +69,443 @@ func {{ $name }}ArrTo{{ . | ToTitle }}PtrArr(src []{{ $name }}) (dst []*{{ . }})
 func {{ $name }}PtrArrTo{{ . | ToTitle }}Arr(src []*{{ $name }}) (dst []{{ . }}) {
 	dst = make([]{{ . }}, len(src))
 	for i := range src {
-		dst[i] = {{ . }}(*src[i])
+		if src[i] != nil {
+			dst[i] = {{ . }}(*src[i])
+		}
+	}
+	return dst
+}
+func {{ $name }}PtrArrTo{{ . | ToTitle }}PtrArr(src []*{{ $name }}) (dst []*{{ . }}) {
+	dst = make([]*{{ . }}, len(src))
+	for i := range src {
+		if src[i] != nil {
+			dst[i] = {{ . }}Ptr({{ . }}(*src[i]))
+		}
 	}
 	return dst
 }
 {{- end }}
 {{- end }}
+{{- range .CheckedCastList }}
+func {{ .Src }}To{{ .Dst | ToTitle }}Checked(src {{ .Src }}) ({{ .Dst }}, error) {
+	dst := {{ .Dst }}(src)
+	{{- if .Float }}
+	if math.IsInf(float64(dst), 0) && !math.IsInf(src, 0) {
+	{{- else }}
+	if {{ .Src }}(dst) != src || (src < 0) != (dst < 0) {
+	{{- end }}
+		return dst, fmt.Errorf("%v can't be converted to {{ .Dst }} without loss", src)
+	}
+	return dst, nil
+}
+{{- end }}
+{{- end }}
 {{- range .Mappers }}
 {{- $mapper := . }}
-func {{ .MapperFuncName }}({{- range  $index, $element := .SrcList }}{{if $index}}, {{end}}{{ $element.Alias }}  *{{ $element.ShortPath }}{{- end }}) ({{ .Dst.Alias }} *{{ .Dst.ShortPath }}) {
+{{- if .FieldSources }}
+// {{ .MapperFuncName }} maps the sources into {{ .Dst.ShortPath }}, {{ .Merge }}:
+{{- range .FieldSources }}
+//	{{ . }}
+{{- end }}
+{{- end }}
+func {{ .MapperFuncName }}{{ template "mapperSignature" . }} {
 	{{- $dst := .Dst }}
-	{{- range .SrcList }}
+	{{- if .Graph }}
+	return {{ .Graph.FuncName }}({{ template "mapperArgs" . }}, {{ .Graph.New }})
+}
+func {{ .Graph.FuncName }}{{ template "graphMapperSignature" . }} {
+	{{- $src := index .SrcList 0 }}
+	if {{ $src.Alias }} == nil || !graph.Enter() {
+		return nil{{ if .ReturnsError }}, nil{{ end }}
+	}
+	defer graph.Leave()
+	{{- if .Graph.Cycles }}
+	if v, ok := graph.Lookup("{{ .MapperFuncName }}", {{ $src.Alias }}); ok {
+		return v.(*{{ $dst.ShortPath }}){{ if .ReturnsError }}, nil{{ end }}
+	}
+	{{- end }}
+	{{- with .Before }}
+	{{- template "hookCall" . }}
+	{{- end }}
+	{{ $dst.Alias }} = &{{ $dst.ShortPath }}{}
+	{{- if .Graph.Cycles }}
+	graph.Store("{{ .MapperFuncName }}", {{ $src.Alias }}, {{ $dst.Alias }})
+	{{- end }}
+	{{- range .FieldMappingRules }}
+	{{- if .SrcAlias }}
+	{{- template "fieldMappingRule" . }}
+	{{- end }}
+	{{- end }}
+	{{- range .FieldMappingRules }}
+	{{- if not .SrcAlias }}
+	{{- template "fieldMappingRule" . }}
+	{{- end }}
+	{{- end }}
+	{{- with .After }}
+	{{- template "hookCall" . }}
+	{{- end }}
+	{{- range .Validation }}
+	{{- template "hookCall" . }}
+	{{- end }}
+	{{- else if .InputPtr }}
+	{{- with .Before }}
+	{{- template "hook" . }}
+	{{- end }}
+	{{- range .MergeList }}
+	{{- $src := . }}
 	if {{ .Alias }} != nil {
+		{{- if $mapper.DstNew }}
 		if {{ $dst.Alias }} == nil {
-			{{ $dst.Alias }} = &{{ $dst.ShortPath }}{}
+			{{ $dst.Alias }} = {{ $mapper.DstNew }}
 		}
+		{{- end }}
 		{{- range $mapper.FieldMappingRules }}
-		{{- if .Casted }}
-		{{- if .SrcFieldPtr }}
-		if {{ .SrcAlias }}.{{ .SrcFieldName }} != nil {
-			{{ $dst.Alias }}.{{ .DstFieldName }} = {{ .CastStr }}
-		}
-		{{- else }}
-		{{ $dst.Alias }}.{{ .DstFieldName }} = {{ .CastStr }}
+		{{- if eq .SrcAlias $src.Alias }}
+		{{- template "fieldMappingRule" . }}
 		{{- end }}
-		{{- else }}
-		//{{ $dst.Alias }}.{{ .DstFieldName }} = {{ .CastStr }}
+		{{- end }}
+	}
+	{{- end }}
+	{{- if .UnsourcedRules }}
+	if {{ if .OutputPtr }}{{ $dst.Alias }} != nil{{ else }}{{ range $index, $element := .SrcList }}{{ if $index }} || {{ end }}{{ .Alias }} != nil{{ end }}{{ end }} {
+		{{- range .FieldMappingRules }}
+		{{- if not .SrcAlias }}
+		{{- template "fieldMappingRule" . }}
 		{{- end }}
 		{{- end }}
 	}
 	{{- end }}
-	return {{ .Dst.Alias }}
+	{{- else }}
+	{{- with .Before }}
+	{{- template "hook" . }}
+	{{- end }}
+	{{- if .DstNew }}
+	{{ $dst.Alias }} = {{ .DstNew }}
+	{{- end }}
+	{{- range .MergeList }}
+	{{- $src := . }}
+	{{- range $mapper.FieldMappingRules }}
+	{{- if eq .SrcAlias $src.Alias }}
+	{{- template "fieldMappingRule" . }}
+	{{- end }}
+	{{- end }}
+	{{- end }}
+	{{- range .FieldMappingRules }}
+	{{- if not .SrcAlias }}
+	{{- template "fieldMappingRule" . }}
+	{{- end }}
+	{{- end }}
+	{{- end }}
+	{{- if not .Graph }}
+	{{- with .After }}
+	{{- template "hook" . }}
+	{{- end }}
+	{{- range .Validation }}
+	{{- template "hook" . }}
+	{{- end }}
+	{{- end }}
+	return {{ .Dst.Alias }}{{ if .ReturnsError }}, nil{{ end }}
 }
-func {{ .ListMapperFuncName }}({{- range  $index, $element := .SrcList }}{{if $index}}, {{end}}{{ $element.Alias }}  []*{{ $element.ShortPath }}{{- end }}) ({{ .Dst.Alias }} []*{{ .Dst.ShortPath }}) {
+func {{ .ListMapperFuncName }}{{ template "listMapperSignature" . }} {
+	{{- $first := index .SrcList 0 }}
+	{{- with .BeforeList }}
+	{{- template "hookCall" . }}
+	{{- end }}
+	{{- if eq .List "join" }}
+	{{- range .ListSources }}
+	{{- if not .Primary }}
+	{{ .Alias }}Index := make(map[{{ .KeyType }}]{{ $mapper.InputPtr }}{{ .ShortPath }}, len({{ .Alias }}))
+	for {{ if .IndexPos }}j{{ else }}_{{ end }}, v := range {{ .Alias }} {
+		{{- if $mapper.ListInputPtr }}
+		if v != nil {
+			{{ .Alias }}Index[{{ .Key }}] = {{ .IndexItem }}
+		}
+		{{- else }}
+		{{ .Alias }}Index[{{ .Key }}] = {{ .IndexItem }}
+		{{- end }}
+	}
+	{{- end }}
+	{{- end }}
+	count := len({{ $first.Alias }})
+	{{- else if eq .List "zip_longest" }}
 	var count int
 	{{- range .SrcList }}
-	if count == 0 || count > len({{ .Alias }}) {
+	if count < len({{ .Alias }}) {
+		count = len({{ .Alias }})
+	}
+	{{- end }}
+	{{- else if eq .List "strict" }}
+	count := len({{ $first.Alias }})
+	{{- range $index, $element := .SrcList }}
+	{{- if $index }}
+	if len({{ .Alias }}) != count {
+		return nil, fmt.Errorf("{{ $mapper.ListMapperFuncName }}: sources length mismatch: {{ range $index, $element := $mapper.SrcList }}{{ if $index }}, {{ end }}%d{{ end }}", {{ range $index, $element := $mapper.SrcList }}{{ if $index }}, {{ end }}len({{ .Alias }}){{ end }})
+	}
+	{{- end }}
+	{{- end }}
+	{{- else }}
+	count := len({{ $first.Alias }})
+	{{- range $index, $element := .SrcList }}
+	{{- if $index }}
+	if count > len({{ .Alias }}) {
 		count = len({{ .Alias }})
 	}
 	{{- end }}
-	{{ .Dst.Alias }} = make([]*{{ .Dst.ShortPath }}, 0, count)
+	{{- end }}
+	{{- end }}
+	{{- with .Graph }}
+	graph := {{ .New }}
+	{{- end }}
+	{{ .Dst.Alias }} = make([]{{ .ListOutputPtr }}{{ .Dst.ShortPath }}, 0, count)
 	for i := 0; i < count; i++ {
-    	{{ .Dst.Alias }} = append(dst, {{ .MapperFuncName }}({{- range $index, $element := .SrcList }}{{if $index}}, {{end}}{{ $element.Alias }}[i]{{- end }}))
-    }
-	return {{ .Dst.Alias }}
+		{{- if and (eq .List "join") .ListInputPtr }}
+		{{- range .ListSources }}
+		{{- if not .Primary }}
+		var {{ .Alias }}Item {{ $mapper.InputPtr }}{{ .ShortPath }}
+		{{- end }}
+		{{- end }}
+		if {{ $first.Alias }}[i] != nil {
+			{{- range .ListSources }}
+			{{- if not .Primary }}
+			{{ .Alias }}Item = {{ .Alias }}Index[{{ .PrimaryKey }}]
+			{{- end }}
+			{{- end }}
+		}
+		{{- else if eq .List "join" }}
+		{{- range .ListSources }}
+		{{- if not .Primary }}
+		{{ .Alias }}Item := {{ .Alias }}Index[{{ .PrimaryKey }}]
+		{{- end }}
+		{{- end }}
+		{{- else if eq .List "zip_longest" }}
+		{{- range .ListSources }}
+		var {{ .Alias }}Item {{ $mapper.ListInputPtr }}{{ .ShortPath }}
+		if i < len({{ .Alias }}) {
+			{{ .Alias }}Item = {{ .Alias }}[i]
+		}
+		{{- end }}
+		{{- end }}
+		{{- if .ReturnsError }}
+		v, err := {{ .ItemFuncName }}({{ if .ContextParam }}ctx, {{ end }}{{- range $index, $element := .ListSources }}{{if $index}}, {{end}}{{ $element.Item }}{{- end }}{{ .ItemArgs }})
+		if err != nil {
+			return nil, fmt.Errorf("index %d: %w", i, err)
+		}
+		{{ .Dst.Alias }} = append({{ .Dst.Alias }}, {{ .ListItem }})
+		{{- else if ne .ListItem "v" }}
+		v := {{ .ItemFuncName }}({{ if .ContextParam }}ctx, {{ end }}{{- range $index, $element := .ListSources }}{{if $index}}, {{end}}{{ $element.Item }}{{- end }}{{ .ItemArgs }})
+		{{ .Dst.Alias }} = append({{ .Dst.Alias }}, {{ .ListItem }})
+		{{- else }}
+		{{ .Dst.Alias }} = append({{ .Dst.Alias }}, {{ .ItemFuncName }}({{ if .ContextParam }}ctx, {{ end }}{{- range $index, $element := .ListSources }}{{if $index}}, {{end}}{{ $element.Item }}{{- end }}{{ .ItemArgs }}))
+		{{- end }}
+	}
+	{{- with .AfterList }}
+	{{- template "hookCall" . }}
+	{{- end }}
+	return {{ .Dst.Alias }}{{ if .ListReturnsError }}, nil{{ end }}
+}
+{{- if .MapMapperFuncName }}
+func {{ .MapMapperFuncName }}[K comparable]({{ if .ContextParam }}{{ .ContextParam }}, {{ end }}{{ $first.Alias }} map[K]{{ .InputPtr }}{{ $first.ShortPath }}{{ template "params" . }}) ({{ .Dst.Alias }} map[K]{{ .OutputPtr }}{{ .Dst.ShortPath }}{{ if .ReturnsError }}, err error{{ end }}) {
+	{{- with .Graph }}
+	graph := {{ .New }}
+	{{- end }}
+	{{ .Dst.Alias }} = make(map[K]{{ .OutputPtr }}{{ .Dst.ShortPath }}, len({{ $first.Alias }}))
+	for k, v := range {{ $first.Alias }} {
+		{{- if .ReturnsError }}
+		item, err := {{ .ItemFuncName }}({{ if .ContextParam }}ctx, {{ end }}v{{ .ItemArgs }})
+		if err != nil {
+			return nil, fmt.Errorf("key %v: %w", k, err)
+		}
+		{{ .Dst.Alias }}[k] = item
+		{{- else }}
+		{{ .Dst.Alias }}[k] = {{ .ItemFuncName }}({{ if .ContextParam }}ctx, {{ end }}v{{ .ItemArgs }})
+		{{- end }}
+	}
+	return {{ .Dst.Alias }}{{ if .ReturnsError }}, nil{{ end }}
+}
+{{- end }}
+{{- with .Index }}
+func {{ $mapper.IndexMapperFuncName }}{{ template "indexMapperSignature" $mapper }} {
+	{{- with $mapper.Graph }}
+	graph := {{ .New }}
+	{{- end }}
+	{{ $mapper.Dst.Alias }} = make(map[{{ .KeyType }}]{{ $mapper.ListOutputPtr }}{{ $mapper.Dst.ShortPath }}, len({{ $first.Alias }}))
+	for {{ if eq $mapper.IndexArg "v" "*v" }}_{{ else }}j{{ end }}, v := range {{ $first.Alias }} {
+		{{- if $mapper.ListInputPtr }}
+		if v == nil {
+			continue
+		}
+		{{- end }}
+		key := {{ .Key }}
+		{{- if eq .Duplicates "first" }}
+		if _, exist := {{ $mapper.Dst.Alias }}[key]; exist {
+			continue
+		}
+		{{- else if eq .Duplicates "error" }}
+		if _, exist := {{ $mapper.Dst.Alias }}[key]; exist {
+			return nil, fmt.Errorf("{{ $mapper.IndexMapperFuncName }}: duplicate key %v", key)
+		}
+		{{- end }}
+		{{- if $mapper.ReturnsError }}
+		item, err := {{ $mapper.ItemFuncName }}({{ if $mapper.ContextParam }}ctx, {{ end }}{{ $mapper.IndexArg }}{{ $mapper.ItemArgs }})
+		if err != nil {
+			return nil, fmt.Errorf("key %v: %w", key, err)
+		}
+		{{ $mapper.Dst.Alias }}[key] = {{ $mapper.IndexItem }}
+		{{- else if ne $mapper.IndexItem "item" }}
+		item := {{ $mapper.ItemFuncName }}({{ if $mapper.ContextParam }}ctx, {{ end }}{{ $mapper.IndexArg }}{{ $mapper.ItemArgs }})
+		{{ $mapper.Dst.Alias }}[key] = {{ $mapper.IndexItem }}
+		{{- else }}
+		{{ $mapper.Dst.Alias }}[key] = {{ $mapper.ItemFuncName }}({{ if $mapper.ContextParam }}ctx, {{ end }}{{ $mapper.IndexArg }}{{ $mapper.ItemArgs }})
+		{{- end }}
+	}
+	return {{ $mapper.Dst.Alias }}{{ if $mapper.IndexReturnsError }}, nil{{ end }}
+}
+{{- end }}
+{{- end }}
+{{- range .Converters }}
+{{- $converter := . }}
+{{- if .Declare }}
+
+// {{ .Name }} converts with the {{ range $index, $element := .Methods }}{{ if $index }}, {{ end }}{{ .Name }}{{ end }} mappers.
+type {{ .Name }} interface {
+	{{- range .Methods }}
+	{{ .Name }}{{ template "methodSignature" . }}
+	{{- end }}
+}
+{{- end }}
+
+// {{ .ImplName }} implements {{ .Name }} with the generated mappers.
+type {{ .ImplName }} struct{}
+
+var _ {{ .Name }} = {{ .ImplName }}{}
+
+// {{ .Constructor }} returns the generated {{ .Name }}.
+func {{ .Constructor }}() {{ .Name }} {
+	return {{ .ImplName }}{}
+}
+{{- range .Methods }}
+func ({{ $converter.ImplName }}) {{ .Name }}{{ template "methodSignature" . }} {
+	return {{ .FuncName }}({{ template "mapperArgs" .Mapper }})
+}
+{{- end }}
+{{- end }}
+{{- define "methodSignature" -}}
+{{- if eq .Kind "list" }}{{ template "listMapperSignature" .Mapper }}
+{{- else if eq .Kind "index" }}{{ template "indexMapperSignature" .Mapper }}
+{{- else }}{{ template "mapperSignature" .Mapper }}
+{{- end }}
+{{- end }}
+{{- define "mapperSignature" -}}
+({{ if .ContextParam }}{{ .ContextParam }}, {{ end }}{{- range  $index, $element := .SrcList }}{{if $index}}, {{end}}{{ $element.Alias }} {{ $.InputPtr }}{{ $element.ShortPath }}{{- end }}{{ template "params" . }}) ({{ .Dst.Alias }} {{ .OutputPtr }}{{ .Dst.ShortPath }}{{ if .ReturnsError }}, err error{{ end }})
+{{- end }}
+{{- define "graphMapperSignature" -}}
+{{- $first := index .SrcList 0 -}}
+({{ if .ContextParam }}{{ .ContextParam }}, {{ end }}{{ $first.Alias }} *{{ $first.ShortPath }}{{ template "params" . }}, graph *{{ .Graph.Type }}) ({{ .Dst.Alias }} *{{ .Dst.ShortPath }}{{ if .ReturnsError }}, err error{{ end }})
+{{- end }}
+{{- define "listMapperSignature" -}}
+({{ if .ContextParam }}{{ .ContextParam }}, {{ end }}{{- range  $index, $element := .SrcList }}{{if $index}}, {{end}}{{ $element.Alias }} []{{ $.ListInputPtr }}{{ $element.ShortPath }}{{- end }}{{ template "params" . }}) ({{ .Dst.Alias }} []{{ .ListOutputPtr }}{{ .Dst.ShortPath }}{{ if .ListReturnsError }}, err error{{ end }})
+{{- end }}
+{{- define "indexMapperSignature" -}}
+{{- $first := index .SrcList 0 -}}
+({{ if .ContextParam }}{{ .ContextParam }}, {{ end }}{{ $first.Alias }} []{{ .ListInputPtr }}{{ $first.ShortPath }}{{ template "params" . }}) ({{ .Dst.Alias }} map[{{ .Index.KeyType }}]{{ .ListOutputPtr }}{{ .Dst.ShortPath }}{{ if .IndexReturnsError }}, err error{{ end }})
+{{- end }}
+{{- define "mapperArgs" -}}
+{{- if .ContextParam }}ctx, {{ end }}
+{{- range  $index, $element := .SrcList }}{{if $index}}, {{end}}{{ $element.Alias }}{{- end }}
+{{- range .Params }}, {{ .Name }}{{ end }}
+{{- end }}
+{{- define "params" -}}
+{{- range .Params }}, {{ .Name }} {{ .Type }}{{ end }}
+{{- end }}
+{{- define "hook" }}
+{{- if .Guard }}
+if {{ .Guard }} {
+	{{- template "hookCall" . }}
+}
+{{- else }}
+{{- template "hookCall" . }}
+{{- end }}
+{{- end }}
+{{- define "hookCall" }}
+{{- if .ReturnsError }}
+if err := {{ .Call }}; err != nil {
+	return {{ .Zero }}, fmt.Errorf("{{ .Label }}: %w", err)
+}
+{{- else }}
+{{ .Call }}
+{{- end }}
+{{- end }}
+{{- define "fieldMappingRule" }}
+{{- if and .Guard .Casted }}
+if {{ .Guard }} {
+	{{- template "fieldAssignment" . }}
+}
+{{- else }}
+{{- template "fieldAssignment" . }}
+{{- end }}
+{{- end }}
+{{- define "fieldAssignment" }}
+{{- if .Variants }}
+switch v := {{ .CastStr }}.(type) {
+{{- range .Variants }}
+case {{ .Type }}:
+	{{- if .Nilable }}
+	if v != nil {
+		{{- template "variantAssignment" ($.VariantAssignment .) }}
+	}
+	{{- else }}
+	{{- template "variantAssignment" ($.VariantAssignment .) }}
+	{{- end }}
+{{- end }}
+{{- if .UnexpectedVariants }}
+case nil:
+default:
+	return {{ .Zero }}, fmt.Errorf("{{ .MapperFuncName }}: {{ .DstFieldName }}: unexpected %T", v)
+{{- end }}
+}
+{{- else if .Checked }}
+{
+	v, err := {{ .CastStr }}
+	if err != nil {
+		return {{ .Zero }}, fmt.Errorf("{{ .MapperFuncName }}: {{ .DstFieldName }}: %w", err)
+	}
+	{{ .DstRow }} = {{ if .CastAddr }}&{{ end }}{{ if .CastDeref }}*{{ end }}v
 }
+{{- else if .Casted }}
+{{ .DstRow }} = {{ .CastStr }}
+{{- else }}
+//{{ .DstRow }} = {{ .CastStr }}
+{{- end }}
+{{- end }}
+{{- define "variantAssignment" }}
+{{- if .Case.Checked }}
+	mapped, err := {{ .Case.CastStr }}
+	if err != nil {
+		return {{ .Zero }}, fmt.Errorf("{{ .MapperFuncName }}: {{ .DstFieldName }}: %w", err)
+	}
+	{{ .DstRow }} = {{ .Case.Value "mapped" }}
+{{- else }}
+	{{ .DstRow }} = {{ .Case.Value .Case.CastStr }}
+{{- end }}
 {{- end }}`
 )
 
+const (
+	mergeLastWins     = "last_wins"
+	mergeFirstNonNil  = "first_non_nil"
+	mergeFirstNonZero = "first_non_zero"
+)
+
 var (
 	importPackageAliasMap = make(map[string]string, 10)
-	typeToPtrList         = []string{"bool", "string", "byte", "int", "int64", "float32", "float64"}
-	typesCastList         = []struct {
-		Name      string
-		CastTypes []string
-	}{
+	numericTypes          = []string{
+		"byte",
+		"uint", "uint8", "uint16", "uint32", "uint64",
+		"int", "int8", "int16", "int32", "int64",
+		"float32", "float64",
+	}
+	typeToPtrList = append([]string{"bool", "string"}, numericTypes...)
+	typesCastList = append([]typesCast{
 		{
 			Name:      "bool",
 			CastTypes: []string{"bool"},
@@ -124,35 +514,57 @@ var (
 			Name:      "string",
 			CastTypes: []string{"string"},
 		},
-		{
-			Name:      "int",
-			CastTypes: []string{"int", "int64"},
-		},
-		{
-			Name:      "int64",
-			CastTypes: []string{"int", "int64"},
-		},
-		{
-			Name:      "float32",
-			CastTypes: []string{"float32", "float64"},
-		},
-		{
-			Name:      "float64",
-			CastTypes: []string{"float32", "float64"},
-		},
-	}
+	}, numericTypesCastList()...)
 	templateFuncMap = template.FuncMap{
 		"ToTitle": strings.Title,
 	}
 )
 
+// reservedPackageAliasMap holds the aliases of the packages the generated
+// file refers to when it imports them.
+var reservedPackageAliasMap = make(map[string]string, 10)
+
+// outPackagePath is the package of the generated file, its names are
+// spelled unqualified and it isn't imported.
+var outPackagePath string
+
+type typesCast struct {
+	Name      string
+	CastTypes []string
+}
+
+// numericTypesCastList lists every numeric type as convertible into every
+// other numeric type, so each helper castDstField refers to is generated.
+func numericTypesCastList() []typesCast {
+	list := make([]typesCast, 0, len(numericTypes))
+	for _, numericType := range numericTypes {
+		list = append(list, typesCast{
+			Name:      numericType,
+			CastTypes: numericTypes,
+		})
+	}
+	return list
+}
+
 type sourceConfig struct {
 	Alias string `yaml:"alias"`
 	Path  string `yaml:"path"`
+	// JoinKey is the expression of the source alias the join list mode
+	// matches the elements on, e.g. profile.UserID, the join key field of
+	// the mapper by default.
+	JoinKey string `yaml:"join_key"`
 }
 
+// StructureName returns the structure name followed by the names of its
+// type arguments, e.g. PageUser for a Page[User] instance.
 func (sc sourceConfig) StructureName() string {
-	_, structureName, _ := parsePackageAndStructure(sc.Path)
+	structureName, typeArgPaths := splitTypeArgs(sc.Path)
+	if _, name, err := parsePackageAndStructure(sc.Path); err == nil {
+		structureName = name
+	}
+	for _, typeArgPath := range typeArgPaths {
+		structureName += strings.Title(sourceConfig{Path: typeArgPath}.StructureName())
+	}
 	return structureName
 }
 
@@ -162,29 +574,176 @@ type mapperConfig struct {
 	Sources     []sourceConfig `yaml:"source"`
 	Mapping     []string       `yaml:"map"`
 	Relations   []string       `yaml:"relations"`
+	// ConvertNamedTypes allows conversions between distinct named types
+	// sharing an underlying type, e.g. model.UserID to pb.UserID.
+	ConvertNamedTypes bool `yaml:"convert_named_types"`
+	// Checked makes the mapper return an error instead of silently
+	// overflowing or truncating narrowed numeric values.
+	Checked bool `yaml:"checked"`
+	// Merge decides which source feeds a destination field several sources
+	// provide: last_wins (default), first_non_nil or first_non_zero.
+	Merge string `yaml:"merge"`
+	// FieldSources pins destination fields to the alias of the source
+	// feeding them.
+	FieldSources map[string]string `yaml:"field_sources"`
+	// List decides how the list mapper pairs the elements of several
+	// sources: zip_shortest (default), zip_longest, strict or join.
+	List string `yaml:"list"`
+	// JoinKey is the field the join list mode matches elements on, the
+	// default of the join key expressions of the sources.
+	JoinKey string `yaml:"join_key"`
+	// JoinKeyType is the type of the join key expressions, required when
+	// it can't be told from a source field.
+	JoinKeyType string `yaml:"join_key_type"`
+	// MapMapper adds a mapper converting map[K]*Src into map[K]*Dst.
+	MapMapper bool `yaml:"map_mapper"`
+	// Index adds a mapper converting []*Src into map[Key]*Dst.
+	Index *indexConfig `yaml:"index"`
+	// Input and Output decide whether the mapper takes its sources and
+	// returns its destination by pointer (default) or by value.
+	Input  string `yaml:"input"`
+	Output string `yaml:"output"`
+	// ListInput and ListOutput decide whether the list and the index
+	// mappers take []*Src or []Src and return []*Dst or []Dst, the input
+	// and the output forms by default.
+	ListInput  string `yaml:"list_input"`
+	ListOutput string `yaml:"list_output"`
+	// Converter is the name of the interface the mapper is a method of,
+	// mappers sharing it are grouped into a single converter. Map mappers
+	// are generic and stay functions.
+	Converter string `yaml:"converter"`
+	// Errors makes the mapper return an error even when nothing fails.
+	Errors bool `yaml:"errors"`
+	// Ignore lists the destination fields left unmapped.
+	Ignore []string `yaml:"ignore"`
+	// DeepCopy makes a mapper of a structure into itself clone pointers,
+	// slices, maps and nested structures instead of sharing them.
+	DeepCopy bool `yaml:"deep_copy"`
+	// Cycles makes the mapper track the source structures it mapped, so
+	// structures reached again map to the same destination instead of
+	// recursing forever.
+	Cycles bool `yaml:"cycles"`
+	// MaxDepth stops mapping nested structures deeper than that, leaving
+	// them nil.
+	MaxDepth int `yaml:"max_depth"`
+	// Context makes the mappers take a context.Context first, passed on to
+	// the custom converters and the nested mappers taking it.
+	Context bool `yaml:"context"`
+	// Params are extra parameters of the mappers following the sources.
+	// They fill the destination fields of their name no source provides.
+	Params []paramConfig `yaml:"params"`
+	// Constants always set the destination fields, Defaults set them when
+	// the source fields mapped into them are missing or zero. Strings are
+	// string literals, Go expressions are given as {expr: model.StatusActive}.
+	Constants map[string]interface{} `yaml:"constants"`
+	Defaults  map[string]interface{} `yaml:"defaults"`
+	// Conditions are the expressions the sources must meet for the
+	// destination fields to be mapped.
+	Conditions map[string]string `yaml:"conditions"`
+	// Variants are the concrete types the interface source fields hold,
+	// mapped by a type switch into the destination fields of their names.
+	Variants map[string][]variantConfig `yaml:"variants"`
+	// Before and After are the functions called with the sources before
+	// mapping them and with the sources and the destination after, the
+	// extra parameters following, they return an error when the mapper
+	// does. BeforeList and AfterList are those of the list mapper.
+	Before     string `yaml:"before"`
+	After      string `yaml:"after"`
+	BeforeList string `yaml:"before_list"`
+	AfterList  string `yaml:"after_list"`
+	// Validate makes the mapper call the Validate() error method of the
+	// destination implementing one, Validator is a function validating the
+	// destination. Both make the mapper return an error.
+	Validate  bool   `yaml:"validate"`
+	Validator string `yaml:"validator"`
+	// MapKey is the tag naming the map keys of the structure fields when
+	// the source or the destination path is map, the field names otherwise.
+	// TimeFormat is the layout times are formatted into map values with,
+	// time.RFC3339 by default.
+	MapKey     string `yaml:"map_key"`
+	TimeFormat string `yaml:"time_format"`
+	// UUIDStrings formats uuid.UUID fields into string fields and, in
+	// mappers returning an error, parses string fields into uuid.UUID ones.
+	UUIDStrings bool `yaml:"uuid_strings"`
+
+	// converterInterface and converterImpl are the existing interface the
+	// mapper implements the method of and its implementing type, method
+	// is the name of that method taking lists when listMethod is set.
+	converterInterface string
+	converterImpl      string
+	method             string
+	listMethod         bool
+	// cloneHelper marks the unexported deep copy mappers generated for the
+	// structures deep copy mappers reach.
+	cloneHelper bool
+	// dynamicHelper marks the unexported map mappers generated for the
+	// structures map mappers reach.
+	dynamicHelper bool
 }
 
 func (mc mapperConfig) MapperName() string {
+	return mc.funcName("Mapper")
+}
+
+// funcName names the mapper function of the kind, deep copy mappers are
+// named CloneX, CloneXList and so on. Map mappers without an alias are
+// named after their structure and direction, XToMap and XFromMap.
+func (mc mapperConfig) funcName(kind string) string {
 	prefix := mc.Alias
-	if len(prefix) == 0 {
+	switch {
+	case len(prefix) != 0:
+	case mc.MapDestination() && len(mc.Sources) == 1:
+		prefix = mc.Sources[0].StructureName() + dynamicMapDirection(mc)
+	case mc.MapSource():
+		prefix = mc.Destination.StructureName() + dynamicMapDirection(mc)
+	default:
 		prefix = mc.Destination.StructureName()
 	}
-	return fmt.Sprintf("%sMapper", prefix)
+	switch {
+	case mc.cloneHelper:
+		return fmt.Sprintf("clone%s%s", prefix, strings.TrimSuffix(kind, "Mapper"))
+	case mc.dynamicHelper:
+		return lowerFirst(prefix + kind)
+	case mc.DeepCopy:
+		return fmt.Sprintf("Clone%s%s", prefix, strings.TrimSuffix(kind, "Mapper"))
+	}
+	return prefix + kind
+}
+
+// ReturnsError reports whether the generated mapper returns an error.
+func (mc mapperConfig) ReturnsError() bool {
+	return mc.Checked || mc.Errors || mc.Validate || len(mc.Validator) != 0 || mc.MapSource()
+}
+
+// ListReturnsError reports whether the generated list mapper returns an
+// error.
+func (mc mapperConfig) ListReturnsError() bool {
+	return mc.ReturnsError() || mc.List == listStrict
 }
 
 func (mc mapperConfig) ListMapperName() string {
-	prefix := mc.Alias
-	if len(prefix) == 0 {
-		prefix = mc.Destination.StructureName()
-	}
-	return fmt.Sprintf("%sListMapper", prefix)
+	return mc.funcName("ListMapper")
 }
 
 type config struct {
-	path    string
-	out     string
+	path string
+	out  string
+	// Helpers selects where conversion helpers come from: "runtime", the
+	// default, calls the mapping package, "inline" stamps them into the
+	// generated file.
+	Helpers string          `yaml:"helpers"`
 	Imports []importPackage `yaml:"imports"`
 	Mappers []mapperConfig  `yaml:"mappers"`
+	// Interfaces are implemented by mappers of their methods.
+	Interfaces []interfaceConfig `yaml:"interfaces"`
+	// Packages are the directories whose structures declare mappers by
+	// comment directives.
+	Packages []string `yaml:"packages"`
+	// Funcs are the custom converters of the field types.
+	Funcs []funcConfig `yaml:"funcs"`
+	// Presence is the prefix of the source methods telling a field is set,
+	// Has by default, none disables the presence checks.
+	Presence string `yaml:"presence"`
 }
 
 func main() {
@@ -233,28 +792,182 @@ type src struct {
 	Alias     string
 	ShortPath string
 	Fields    []field
+	// Methods tells the methods of the source, true for predicates.
+	Methods map[string]bool
+	// Oneofs are the wrappers of the oneof fields of protobuf messages.
+	Oneofs map[string][]oneofWrapper
 }
 
 type field struct {
-	Name    string
-	Ptr     bool
-	TypeStr string
+	Name       string
+	Ptr        bool
+	TypeStr    string
+	Underlying string
+	// Tag is the quoted tag of the structure field, Key the map key of the
+	// fields of map sources and destinations.
+	Tag string
+	Key string
+}
+
+type castOptions struct {
+	// mapperName names the mapper in the generation logs.
+	mapperName        string
+	convertNamedTypes bool
+	// returnsError tells the mapper returns an error, so error returning
+	// mappers can be called for nested fields.
+	returnsError bool
+	mappers      map[[2]string]nestedMapper
+	// graph tells the mapper threads a graph cycle-aware nested mappers
+	// share.
+	graph bool
+	// funcs are the custom converters, context tells the mapper passes its
+	// context to the converters taking it.
+	funcs   []funcConfig
+	context bool
+	// params are the extra parameters the mapper passes on to the nested
+	// mappers taking them.
+	params []paramConfig
+	// uuidStrings converts between uuid.UUID and string fields.
+	uuidStrings bool
+	// inlineHelpers tells to call the helpers stamped into the generated
+	// file instead of the mapping package ones.
+	inlineHelpers bool
+}
+
+type fieldMappingRule struct {
+	MapperFuncName string
+	DstAlias       string
+	DstFieldName   string
+	SrcAlias       string
+	SrcShortPath   string
+	SrcFieldName   string
+	SrcFieldPtr    bool
+	CastStr        string
+	Casted         bool
+	// Guard is the condition the source field must meet to be mapped.
+	Guard string
+	// Checked rules cast with an expression returning the value and an
+	// error, CastAddr tells to assign the address of that value and
+	// CastDeref the value it points to.
+	Checked   bool
+	CastAddr  bool
+	CastDeref bool
+	// Zero is the destination returned along with an error.
+	Zero string
+	// DstKey is the key of the map destinations the rule assigns.
+	DstKey string
+	// Variants are the cases of the type switch mapping the interface
+	// source field, CastStr being that field. UnexpectedVariants makes the
+	// switch return an error for the non-nil values no case maps.
+	Variants           []variantCase
+	UnexpectedVariants bool
+}
+
+// DstRow returns the destination field the rule assigns, the key of map
+// destinations.
+func (rule fieldMappingRule) DstRow() string {
+	if len(rule.DstKey) != 0 {
+		return fmt.Sprintf("%s[%s]", rule.DstAlias, strconv.Quote(rule.DstKey))
+	}
+	return fmt.Sprintf("%s.%s", rule.DstAlias, rule.DstFieldName)
+}
+
+// variantAssignment is the assignment of the destination field by a case
+// of the type switch of the rule.
+type variantAssignment struct {
+	fieldMappingRule
+	Case variantCase
+}
+
+// VariantAssignment returns the assignment of the destination field the
+// variant case assigns.
+func (rule fieldMappingRule) VariantAssignment(variantCase variantCase) variantAssignment {
+	if len(variantCase.DstFieldName) != 0 {
+		rule.DstFieldName = variantCase.DstFieldName
+	}
+	return variantAssignment{fieldMappingRule: rule, Case: variantCase}
 }
 
 type mappingParams struct {
 	MapperFuncName     string
 	ListMapperFuncName string
+	ReturnsError       bool
 	Dst                src
 	SrcList            []src
-	FieldMappingRules  []struct {
-		DstFieldName string
-		SrcAlias     string
-		SrcShortPath string
-		SrcFieldName string
-		SrcFieldPtr  bool
-		CastStr      string
-		Casted       bool
-	}
+	FieldMappingRules  []fieldMappingRule
+	// MergeList orders the sources so the one mapped last wins, Merge and
+	// FieldSources describe the outcome for multi-source mappers.
+	MergeList      []src
+	Merge          string
+	FieldSources   []string
+	UnsourcedRules bool
+	// List, ListSources and ListReturnsError shape the list mapper.
+	List             string
+	ListSources      []listSource
+	ListReturnsError bool
+	// MapMapperFuncName and IndexMapperFuncName are set when the map and
+	// the index mappers are generated.
+	MapMapperFuncName   string
+	IndexMapperFuncName string
+	Index               *indexParams
+	IndexReturnsError   bool
+	// InputPtr and OutputPtr prefix the source and the destination types
+	// of the signatures, "*" or nothing for values, ListInputPtr and
+	// ListOutputPtr those of the list and the index mappers. ListItem
+	// adapts the result v of the element mapper to the list, IndexArg
+	// the source v and IndexItem the result item of the index mapper.
+	InputPtr      string
+	OutputPtr     string
+	ListInputPtr  string
+	ListOutputPtr string
+	ListItem      string
+	IndexArg      string
+	IndexItem     string
+	// DstNew creates the destination, empty for values.
+	DstNew string
+	// Graph is set for cycle-aware and depth limited mappers, ItemFuncName
+	// and ItemArgs call the mapper for the list, map and index items.
+	Graph        *graphParams
+	ItemFuncName string
+	ItemArgs     string
+	// ContextParam is the context parameter of context-aware mappers.
+	ContextParam string
+	// Params are the extra parameters following the sources.
+	Params []paramConfig
+	// Before, After, BeforeList and AfterList are the hooks of the mapper
+	// and of the list mapper.
+	Before     *hookParams
+	After      *hookParams
+	BeforeList *hookParams
+	AfterList  *hookParams
+	// Validation are the calls validating the mapped destination.
+	Validation []*hookParams
+}
+
+// converterParams describes a converter interface, its implementation
+// delegating to the mappers and its constructor. Declare tells the
+// interface is generated along with its implementation.
+type converterParams struct {
+	Name        string
+	ImplName    string
+	Constructor string
+	Declare     bool
+	Methods     []converterMethod
+}
+
+const (
+	kindMapper      = "mapper"
+	kindListMapper  = "list"
+	kindIndexMapper = "index"
+)
+
+// converterMethod is a method of the converter calling FuncName, the
+// mapper, the list or the index mapper of Mapper depending on Kind.
+type converterMethod struct {
+	Name     string
+	FuncName string
+	Kind     string
+	Mapper   mappingParams
 }
 
 type importPackage struct {
@@ -263,8 +976,58 @@ type importPackage struct {
 }
 
 func params(mappersConfig *config) (interface{}, error) {
+	var inlineHelpers bool
+	switch mappersConfig.Helpers {
+	case "", helpersRuntime:
+		inlineHelpers = false
+	case helpersInline:
+		inlineHelpers = true
+	default:
+		return nil, fmt.Errorf("unknown helpers mode \"%s\"", mappersConfig.Helpers)
+	}
 	packageName := mapperFilePackage(mappersConfig.out)
+	outPackagePath = mapperFileImportPath(mappersConfig.out)
+	for _, importPackage := range mappersConfig.Imports {
+		if len(importPackage.Alias) != 0 {
+			reservedPackageAliasMap[importPackage.Alias] = importPackage.Path
+		}
+	}
+	for _, interfaceConfig := range mappersConfig.Interfaces {
+		interfaceMappers, err := interfaceMappers(pathUtil.Dir(mappersConfig.path), interfaceConfig)
+		if err != nil {
+			return nil, err
+		}
+		mappersConfig.Mappers = append(mappersConfig.Mappers, interfaceMappers...)
+	}
+	var declaredMappers []mapperConfig
+	for _, packageDir := range mappersConfig.Packages {
+		declared, err := packageMappers(pathUtil.Dir(mappersConfig.path), packageDir)
+		if err != nil {
+			return nil, err
+		}
+		declaredMappers = append(declaredMappers, declared...)
+	}
+	mergedMappers, err := mergeMappers(mappersConfig.Mappers, declaredMappers)
+	if err != nil {
+		return nil, err
+	}
+	mappersConfig.Mappers = mergedMappers
+	cloneHelpers, err := cloneHelperMappers(pathUtil.Dir(mappersConfig.path), mappersConfig.Mappers)
+	if err != nil {
+		return nil, err
+	}
+	mappersConfig.Mappers = append(mappersConfig.Mappers, cloneHelpers...)
+	dynamicHelpers, err := dynamicHelperMappers(pathUtil.Dir(mappersConfig.path), mappersConfig.Mappers)
+	if err != nil {
+		return nil, err
+	}
+	mappersConfig.Mappers = append(mappersConfig.Mappers, dynamicHelpers...)
+	nestedMappers, err := collectNestedMappers(mappersConfig)
+	if err != nil {
+		return nil, err
+	}
 	mappers := make([]mappingParams, 0, len(mappersConfig.Mappers)*2)
+	var checked bool
 	for _, mapperConfig := range mappersConfig.Mappers {
 
 		var dst src
@@ -274,20 +1037,8 @@ func params(mappersConfig *config) (interface{}, error) {
 			return nil, err
 		}
 		dst.ShortPath = shortPath(dstMeta)
-		if err != nil {
-			return nil, err
-		}
-		for _, f := range dstMeta.fields {
-			dst.Fields = append(dst.Fields, struct {
-				Name    string
-				Ptr     bool
-				TypeStr string
-			}{
-				Name:    f.name,
-				Ptr:     isPtr(f.typeAST),
-				TypeStr: typeStrValue(f.typeAST),
-			})
-		}
+		dst.Fields = structFields(dstMeta)
+		dst.Oneofs = structOneofs(dstMeta)
 
 		var srcList []src
 		for _, mapperSrc := range mapperConfig.Sources {
@@ -295,69 +1046,184 @@ func params(mappersConfig *config) (interface{}, error) {
 			if err != nil {
 				return nil, err
 			}
-			var fields []field
-			for _, f := range srcMeta.fields {
-				fields = append(fields, struct {
-					Name    string
-					Ptr     bool
-					TypeStr string
-				}{
-					Name:    f.name,
-					Ptr:     isPtr(f.typeAST),
-					TypeStr: typeStrValue(f.typeAST),
-				})
-			}
 			srcList = append(srcList, src{
 				Alias:     mapperSrc.Alias,
 				ShortPath: shortPath(srcMeta),
-				Fields:    fields,
+				Fields:    structFields(srcMeta),
+				Methods:   structMethods(srcMeta),
+				Oneofs:    structOneofs(srcMeta),
 			})
 		}
 
-		fieldMappingRuleMap := map[string]struct {
-			DstFieldName string
-			SrcAlias     string
-			SrcShortPath string
-			SrcFieldName string
-			SrcFieldPtr  bool
-			CastStr      string
-			Casted       bool
-		}{}
+		merge := mapperConfig.Merge
+		switch merge {
+		case "":
+			merge = mergeLastWins
+		case mergeLastWins, mergeFirstNonNil, mergeFirstNonZero:
+		default:
+			return nil, fmt.Errorf("mapper %s: unknown merge \"%s\"", mapperConfig.MapperName(), merge)
+		}
+		if err := validateSignature(mapperConfig); err != nil {
+			return nil, err
+		}
+		if err := validateParams(mapperConfig); err != nil {
+			return nil, err
+		}
+		if err := validateDynamicMap(mapperConfig, inlineHelpers); err != nil {
+			return nil, err
+		}
+		if mapperConfig.MapDestination() {
+			dst.Fields = dynamicFields(mapperConfig, srcList[0].Fields)
+		}
+		if mapperConfig.MapSource() {
+			srcList[0].Fields = dynamicFields(mapperConfig, dst.Fields)
+		}
+		graph, err := graphMapperParams(mapperConfig, inlineHelpers)
+		if err != nil {
+			return nil, err
+		}
+		zero, dstNew := "nil", "&"+dst.ShortPath+"{}"
+		switch {
+		case mapperConfig.MapDestination():
+			dstNew = fmt.Sprintf("make(%s, %d)", dynamicMapType, len(dst.Fields))
+		case mapperConfig.OutputValue():
+			zero, dstNew = dst.ShortPath+"{}", ""
+		}
+
+		if mapperConfig.DeepCopy {
+			if len(srcList) != 1 || srcList[0].ShortPath != dst.ShortPath {
+				return nil, fmt.Errorf("mapper %s: deep copy requires a single source of the destination type", mapperConfig.MapperName())
+			}
+			if inlineHelpers {
+				return nil, fmt.Errorf("mapper %s: deep copy requires runtime helpers", mapperConfig.MapperName())
+			}
+		}
+		for _, dstFieldName := range mapperConfig.Ignore {
+			if searchField(dst.Fields, dstFieldName) == nil {
+				log.Printf("%s: ignored field %s not found in %s", mapperConfig.MapperName(), dstFieldName, dst.ShortPath)
+			}
+		}
+		for dstFieldName := range mapperConfig.Variants {
+			if searchField(dst.Fields, dstFieldName) == nil {
+				return nil, fmt.Errorf("mapper %s: variants field %s not found in %s", mapperConfig.MapperName(), dstFieldName, dst.ShortPath)
+			}
+		}
+		for dstFieldName, srcAlias := range mapperConfig.FieldSources {
+			if searchSrc(srcList, srcAlias) == nil {
+				return nil, fmt.Errorf("mapper %s: field %s source \"%s\" not found", mapperConfig.MapperName(), dstFieldName, srcAlias)
+			}
+		}
+
+		var dstFieldNames []string
+		fieldMappingRuleMap := map[string][]fieldMappingRule{}
+		options := castOptions{
+			mapperName:        mapperConfig.MapperName(),
+			convertNamedTypes: mapperConfig.ConvertNamedTypes,
+			returnsError:      mapperConfig.ReturnsError(),
+			mappers:           nestedMappers,
+			graph:             graph != nil,
+			funcs:             mappersConfig.Funcs,
+			context:           mapperConfig.Context,
+			params:            mapperConfig.Params,
+			uuidStrings:       mapperConfig.UUIDStrings,
+			inlineHelpers:     inlineHelpers,
+		}
 		for _, dstField := range dst.Fields {
+			if ignored(mapperConfig.Ignore, dstField.Name) {
+				continue
+			}
+			dstFieldNames = append(dstFieldNames, dstField.Name)
 			for _, srcStruct := range srcList {
+				if srcAlias, exist := mapperConfig.FieldSources[dstField.Name]; exist && srcAlias != srcStruct.Alias {
+					continue
+				}
 				for _, srcField := range srcStruct.Fields {
 					if dstField.Name == srcField.Name {
-						var fieldMappingRule struct {
-							DstFieldName string
-							SrcAlias     string
-							SrcShortPath string
-							SrcFieldName string
-							SrcFieldPtr  bool
-							CastStr      string
-							Casted       bool
-						}
+						var fieldMappingRule fieldMappingRule
 						fieldMappingRule.DstFieldName = dstField.Name
 						fieldMappingRule.SrcAlias = srcStruct.Alias
 						fieldMappingRule.SrcShortPath = srcStruct.ShortPath
 						fieldMappingRule.SrcFieldName = srcField.Name
 						fieldMappingRule.SrcFieldPtr = srcField.Ptr
-						fieldMappingRule.CastStr, fieldMappingRule.Casted = castDstField(srcStruct.Alias, srcField, dstField)
-						fieldMappingRuleMap[dstField.Name] = fieldMappingRule
+						fieldMappingRule.Guard = joinGuards(presenceGuard(mappersConfig.Presence, srcStruct, srcField.Name), fieldGuard(merge, srcStruct.Alias, srcField))
+						castStr, funcChecked, customCast := castFuncField(srcStruct.Alias, srcField, dstField, options)
+						if mapperConfig.MapSource() || mapperConfig.MapDestination() {
+							fieldMappingRule.Guard = presenceGuard(mappersConfig.Presence, srcStruct, srcField.Name)
+							fieldMappingRule = castMapField(fieldMappingRule, mapperConfig, srcStruct.Alias, srcField, dstField, options)
+						} else if customCast {
+							fieldMappingRule.CastStr, fieldMappingRule.Checked, fieldMappingRule.Casted = castStr, funcChecked, true
+						} else if mapperConfig.DeepCopy {
+							fieldMappingRule.CastStr, fieldMappingRule.Casted = castCloneField(srcStruct.Alias, srcField, options), true
+						} else {
+							fieldMappingRule.CastStr, fieldMappingRule.Casted = castDstField(srcStruct.Alias, srcField, dstField, options)
+						}
+						if !fieldMappingRule.Casted {
+							fieldMappingRule, _ = castStructpbField(fieldMappingRule, srcStruct.Alias, srcField, dstField, options)
+						}
+						if !fieldMappingRule.Casted {
+							fieldMappingRule, _ = castUUIDField(fieldMappingRule, srcStruct.Alias, srcField, dstField, options)
+						}
+						if !fieldMappingRule.Casted {
+							fieldMappingRule, _ = castSliceArrayField(fieldMappingRule, srcStruct.Alias, srcField, dstField, options)
+						}
+						if !fieldMappingRule.Casted {
+							if castStr, nestedChecked, castAddr, ok := castNestedField(srcStruct.Alias, srcField, dstField, options); ok {
+								fieldMappingRule.CastStr, fieldMappingRule.Checked, fieldMappingRule.CastAddr, fieldMappingRule.Casted = castStr, nestedChecked, castAddr, true
+							}
+						}
+						if mapperConfig.Checked && !customCast {
+							if castStr, castAddr, ok := castCheckedField(srcStruct.Alias, srcField, dstField, options); ok {
+								fieldMappingRule.CastStr, fieldMappingRule.CastAddr, fieldMappingRule.Checked = castStr, castAddr, true
+							}
+						}
+						if variants, exist := mapperConfig.Variants[dstField.Name]; exist {
+							variantCases, err := variantCases(pathUtil.Dir(mappersConfig.path), dstField, variants, options)
+							if err != nil {
+								return nil, fmt.Errorf("mapper %s: %w", mapperConfig.MapperName(), err)
+							}
+							fieldMappingRule.CastStr = fmt.Sprintf("%s.%s", srcStruct.Alias, srcField.Name)
+							fieldMappingRule.Variants, fieldMappingRule.Casted = variantCases, true
+							fieldMappingRule.Checked, fieldMappingRule.CastAddr = false, false
+							fieldMappingRule.UnexpectedVariants = options.returnsError
+							if options.returnsError {
+								useImport("fmt", "fmt")
+							} else {
+								log.Printf("%s: destination field %s is left unset when %s.%s holds a type no variant maps, the mapper returns no error",
+									mapperConfig.MapperName(), dstField.Name, srcStruct.Alias, srcField.Name)
+							}
+						} else if variantCases, ok := oneofVariantCases(srcStruct, dst, dstField.Name, options); ok && !fieldMappingRule.Casted {
+							fieldMappingRule.CastStr = fmt.Sprintf("%s.%s", srcStruct.Alias, srcField.Name)
+							fieldMappingRule.Variants, fieldMappingRule.Casted = variantCases, len(variantCases) != 0
+						}
+						fieldMappingRuleMap[dstField.Name] = append(fieldMappingRuleMap[dstField.Name], fieldMappingRule)
 					}
 				}
 			}
 		}
 
-		for _, relation := range mapperConfig.Relations {
-			var fieldMappingRule struct {
-				DstFieldName string
-				SrcAlias     string
-				SrcShortPath string
-				SrcFieldName string
-				SrcFieldPtr  bool
-				CastStr      string
-				Casted       bool
+		for dstFieldName, rules := range oneofFieldRules(srcList, dst, options) {
+			if !ignored(mapperConfig.Ignore, dstFieldName) && !hasCastedRule(fieldMappingRuleMap[dstFieldName]) {
+				fieldMappingRuleMap[dstFieldName] = rules
+			}
+		}
+
+		for _, dstField := range dst.Fields {
+			if ignored(mapperConfig.Ignore, dstField.Name) {
+				continue
 			}
+			castStr, ok := castParamField(mapperConfig.Params, dstField)
+			switch {
+			case !ok:
+			case hasCastedRule(fieldMappingRuleMap[dstField.Name]):
+				log.Printf("%s: destination field %s is provided by the sources, parameter %s left unused for it",
+					mapperConfig.MapperName(), dstField.Name, strings.TrimPrefix(castStr, "&"))
+			default:
+				fieldMappingRuleMap[dstField.Name] = []fieldMappingRule{{DstFieldName: dstField.Name, CastStr: castStr, Casted: true}}
+			}
+		}
+
+		for _, relation := range mapperConfig.Relations {
+			var fieldMappingRule fieldMappingRule
 
 			_, dstFieldName, srcCastRow := parseRelation(relation)
 
@@ -366,42 +1232,185 @@ func params(mappersConfig *config) (interface{}, error) {
 
 			usedSrc := searchUsedSrc(srcList, srcCastRow)
 			if usedSrc != nil {
+				fieldMappingRule.SrcAlias = usedSrc.Alias
+				fieldMappingRule.SrcShortPath = usedSrc.ShortPath
 				usedField := searchUsedField(usedSrc, srcCastRow)
 				if usedField != nil {
-					fieldMappingRule.SrcAlias = usedSrc.Alias
-					fieldMappingRule.SrcShortPath = usedSrc.ShortPath
 					fieldMappingRule.SrcFieldName = usedField.Name
 					fieldMappingRule.SrcFieldPtr = usedField.Ptr
+					fieldMappingRule.Guard = presenceGuard(mappersConfig.Presence, *usedSrc, usedField.Name)
+					if usedField.Ptr {
+						fieldMappingRule.Guard = joinGuards(fieldMappingRule.Guard, fmt.Sprintf("%s.%s != nil", usedSrc.Alias, usedField.Name))
+					}
+				}
+			}
+			fieldMappingRule.Guard = joinGuards(relationGuard(mapperConfig, srcList, fieldMappingRule.SrcAlias, srcCastRow), fieldMappingRule.Guard)
+
+			if searchField(dst.Fields, dstFieldName) == nil {
+				dstFieldNames = append(dstFieldNames, dstFieldName)
+			}
+			fieldMappingRuleMap[dstFieldName] = append(fieldMappingRuleMap[dstFieldName][:0], fieldMappingRule)
+		}
+
+		constants, defaults, err := valueRules(pathUtil.Dir(mappersConfig.path), mapperConfig, srcList, dst, fieldMappingRuleMap, mappersConfig.Imports, options)
+		if err != nil {
+			return nil, err
+		}
+		for _, constant := range constants {
+			fieldMappingRuleMap[constant.DstFieldName] = []fieldMappingRule{constant}
+		}
+		for dstFieldName := range mapperConfig.Conditions {
+			if searchField(dst.Fields, dstFieldName) == nil {
+				return nil, fmt.Errorf("mapper %s: condition field %s not found in %s", mapperConfig.MapperName(), dstFieldName, dst.ShortPath)
+			}
+		}
+		for _, dstField := range dst.Fields {
+			condition, exist := mapperConfig.Conditions[dstField.Name]
+			if !exist {
+				continue
+			}
+			for i, rule := range fieldMappingRuleMap[dstField.Name] {
+				guard, err := conditionGuard(mapperConfig, srcList, mappersConfig.Imports, rule.SrcAlias, condition)
+				if err != nil {
+					return nil, fmt.Errorf("mapper %s: field %s: %w", mapperConfig.MapperName(), dstField.Name, err)
+				}
+				fieldMappingRuleMap[dstField.Name][i].Guard = joinGuards(rule.Guard, guard)
+			}
+		}
+
+		var fieldMappingRules []fieldMappingRule
+		var fieldSources []string
+		var unsourcedRules bool
+		for _, dstFieldName := range dstFieldNames {
+			var srcRows []string
+			for _, fieldMappingRule := range fieldMappingRuleMap[dstFieldName] {
+				fieldMappingRule.MapperFuncName = mapperConfig.MapperName()
+				fieldMappingRule.DstAlias = dst.Alias
+				fieldMappingRule.Zero = zero
+				if mapperConfig.MapDestination() {
+					fieldMappingRule.DstKey = dstFieldName
+					if dstField := searchField(dst.Fields, dstFieldName); dstField != nil {
+						fieldMappingRule.DstKey = dstField.Key
+					}
+				}
+				fieldMappingRules = append(fieldMappingRules, fieldMappingRule)
+				if !fieldMappingRule.Casted {
+					continue
+				}
+				if len(fieldMappingRule.SrcAlias) == 0 {
+					unsourcedRules = true
 				}
+				srcRows = append(srcRows, fieldMappingRule.CastStr)
+			}
+			if _, oneof := dst.Oneofs[dstFieldName]; len(srcRows) > 1 && !oneof {
+				log.Printf("%s: destination field %s is provided by several sources (%s), %s",
+					mapperConfig.MapperName(), dstFieldName, strings.Join(srcRows, ", "), strings.Replace(merge, "_", " ", -1))
+			}
+			if len(srcRows) != 0 {
+				fieldSources = append(fieldSources, fmt.Sprintf("%s: %s", dstFieldName, strings.Join(srcRows, ", ")))
+			}
+		}
+		fieldMappingRules = mergeOneofRules(fieldMappingRules)
+		for _, defaultRule := range defaults {
+			defaultRule.MapperFuncName = mapperConfig.MapperName()
+			defaultRule.DstAlias = dst.Alias
+			defaultRule.Zero = zero
+			fieldMappingRules = append(fieldMappingRules, defaultRule)
+			unsourcedRules = true
+		}
+		if len(srcList) < 2 {
+			fieldSources = nil
+		}
+		mergeList := srcList
+		if merge != mergeLastWins {
+			mergeList = make([]src, 0, len(srcList))
+			for i := len(srcList) - 1; i >= 0; i-- {
+				mergeList = append(mergeList, srcList[i])
 			}
+		}
 
-			fieldMappingRuleMap[dstFieldName] = fieldMappingRule
+		list, listSources, err := listParams(mapperConfig, srcList, options)
+		if err != nil {
+			return nil, err
 		}
 
-		var fieldMappingRules []struct {
-			DstFieldName string
-			SrcAlias     string
-			SrcShortPath string
-			SrcFieldName string
-			SrcFieldPtr  bool
-			CastStr      string
-			Casted       bool
+		index, err := mapOutputParams(mapperConfig, srcList)
+		if err != nil {
+			return nil, err
+		}
+		var mapMapperFuncName, indexMapperFuncName string
+		if mapperConfig.MapMapper {
+			mapMapperFuncName = mapperConfig.MapMapperName()
 		}
-		for _, fieldMappingRule := range fieldMappingRuleMap {
-			fieldMappingRules = append(fieldMappingRules, fieldMappingRule)
+		if index != nil {
+			indexMapperFuncName = mapperConfig.IndexMapperName()
 		}
 
+		if mapperConfig.ListReturnsError() || (index != nil && mapperConfig.IndexReturnsError()) {
+			useImport("fmt", "fmt")
+		}
+		if mapperConfig.Checked {
+			checked = true
+		}
+		before, after := mapperHooks(mapperConfig, srcList, zero)
+		beforeList, afterList := listHooks(mapperConfig, srcList)
+		itemFuncName, itemArgs := mapperConfig.MapperName(), paramArgs(mapperConfig.Params)
+		indexArg, _ := indexedElemStr(srcList[0].Alias, mapperConfig.ListInputValue(), mapperConfig.InputValue())
+		if graph != nil {
+			itemFuncName, itemArgs = graph.FuncName, itemArgs+", graph"
+		}
 		mappers = append(mappers, mappingParams{
-			MapperFuncName:     mapperConfig.MapperName(),
-			ListMapperFuncName: mapperConfig.ListMapperName(),
-			Dst:                dst,
-			SrcList:            srcList,
-			FieldMappingRules:  fieldMappingRules,
+			MapperFuncName:      mapperConfig.MapperName(),
+			ListMapperFuncName:  mapperConfig.ListMapperName(),
+			ReturnsError:        mapperConfig.ReturnsError(),
+			Dst:                 dst,
+			SrcList:             srcList,
+			FieldMappingRules:   fieldMappingRules,
+			MergeList:           mergeList,
+			Merge:               strings.Replace(merge, "_", " ", -1),
+			FieldSources:        fieldSources,
+			UnsourcedRules:      unsourcedRules,
+			List:                list,
+			ListSources:         listSources,
+			ListReturnsError:    mapperConfig.ListReturnsError(),
+			MapMapperFuncName:   mapMapperFuncName,
+			IndexMapperFuncName: indexMapperFuncName,
+			Index:               index,
+			IndexReturnsError:   mapperConfig.IndexReturnsError(),
+			InputPtr:            signaturePtr(mapperConfig.InputValue()),
+			OutputPtr:           signaturePtr(mapperConfig.OutputValue()),
+			ListInputPtr:        signaturePtr(mapperConfig.ListInputValue()),
+			ListOutputPtr:       signaturePtr(mapperConfig.ListOutputValue()),
+			ListItem:            formStr("v", dst.ShortPath, mapperConfig.OutputValue(), mapperConfig.ListOutputValue(), options),
+			IndexArg:            indexArg,
+			IndexItem:           formStr("item", dst.ShortPath, mapperConfig.OutputValue(), mapperConfig.ListOutputValue(), options),
+			DstNew:              dstNew,
+			Graph:               graph,
+			ItemFuncName:        itemFuncName,
+			ItemArgs:            itemArgs,
+			ContextParam:        contextParams(mapperConfig),
+			Params:              mapperConfig.Params,
+			Before:              before,
+			After:               after,
+			BeforeList:          beforeList,
+			AfterList:           afterList,
+			Validation:          validationHooks(mapperConfig, srcList, zero, inlineHelpers),
 		})
 	}
 
+	converters := collectConverters(mappersConfig.Mappers, mappers)
+
+	var checkedCastList []checkedCast
+	if checked && inlineHelpers {
+		checkedCastList = checkedCastsList()
+		useImport("math", "math")
+	}
+
 	importPackages := make([]importPackage, 0, len(importPackageAliasMap))
 	for alias, packagePath := range importPackageAliasMap {
+		if alias == packagePath {
+			alias = ""
+		}
 		importPackages = append(importPackages, importPackage{
 			Alias: alias,
 			Path:  packagePath,
@@ -414,27 +1423,80 @@ func params(mappersConfig *config) (interface{}, error) {
 		})
 	}
 	return struct {
-		Timestamp      time.Time
-		ConfPath       string
-		PackageName    string
-		ImportPackages []importPackage
-		Mappers        []mappingParams
-		TypeToPtrList  []string
-		TypesCastList  []struct {
-			Name      string
-			CastTypes []string
-		}
+		Timestamp       time.Time
+		ConfPath        string
+		PackageName     string
+		ImportPackages  []importPackage
+		Mappers         []mappingParams
+		InlineHelpers   bool
+		TypeToPtrList   []string
+		TypesCastList   []typesCast
+		CheckedCastList []checkedCast
+		Converters      []converterParams
 	}{
-		Timestamp:      time.Now(),
-		ConfPath:       mappersConfig.path,
-		PackageName:    packageName,
-		ImportPackages: importPackages,
-		TypeToPtrList:  typeToPtrList,
-		TypesCastList:  typesCastList,
-		Mappers:        mappers,
+		InlineHelpers:   inlineHelpers,
+		Timestamp:       time.Now(),
+		ConfPath:        mappersConfig.path,
+		PackageName:     packageName,
+		ImportPackages:  importPackages,
+		TypeToPtrList:   typeToPtrList,
+		TypesCastList:   typesCastList,
+		CheckedCastList: checkedCastList,
+		Mappers:         mappers,
+		Converters:      converters,
 	}, nil
 }
 
+// collectConverters groups the mappers declaring a converter into the
+// converters, each mapper adding its mapper, list and index mappers as
+// methods, or the single method of the interface it was declared by. Map
+// mappers are generic and stay functions.
+func collectConverters(mapperConfigs []mapperConfig, mappers []mappingParams) []converterParams {
+	var converters []converterParams
+	for i, mapperConfig := range mapperConfigs {
+		if len(mapperConfig.Converter) == 0 {
+			continue
+		}
+		var converter *converterParams
+		for j := range converters {
+			if converters[j].Constructor == "New"+mapperConfig.Converter {
+				converter = &converters[j]
+			}
+		}
+		if converter == nil {
+			converters = append(converters, converterParams{
+				Name:        mapperConfig.Converter,
+				ImplName:    mapperConfig.Converter + "Impl",
+				Constructor: "New" + mapperConfig.Converter,
+				Declare:     true,
+			})
+			converter = &converters[len(converters)-1]
+			if len(mapperConfig.converterInterface) != 0 {
+				converter.Name = mapperConfig.converterInterface
+				converter.ImplName = mapperConfig.converterImpl
+				converter.Declare = false
+			}
+		}
+		mapper := mappers[i]
+		if len(mapperConfig.method) != 0 {
+			method := converterMethod{Name: mapperConfig.method, FuncName: mapper.MapperFuncName, Kind: kindMapper, Mapper: mapper}
+			if mapperConfig.listMethod {
+				method.FuncName, method.Kind = mapper.ListMapperFuncName, kindListMapper
+			}
+			converter.Methods = append(converter.Methods, method)
+			continue
+		}
+		converter.Methods = append(converter.Methods,
+			converterMethod{Name: mapper.MapperFuncName, FuncName: mapper.MapperFuncName, Kind: kindMapper, Mapper: mapper},
+			converterMethod{Name: mapper.ListMapperFuncName, FuncName: mapper.ListMapperFuncName, Kind: kindListMapper, Mapper: mapper})
+		if mapper.Index != nil {
+			converter.Methods = append(converter.Methods,
+				converterMethod{Name: mapper.IndexMapperFuncName, FuncName: mapper.IndexMapperFuncName, Kind: kindIndexMapper, Mapper: mapper})
+		}
+	}
+	return converters
+}
+
 func searchUsedField(srcStruct *src, castRow string) *field {
 	for _, srcField := range srcStruct.Fields {
 		if castRow == fmt.Sprintf("%s.%s", srcStruct.Alias, srcField.Name) {
@@ -453,39 +1515,250 @@ func searchUsedField(srcStruct *src, castRow string) *field {
 	return res
 }
 
+func searchField(fields []field, name string) *field {
+	for i := range fields {
+		if fields[i].Name == name {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+func ignored(ignore []string, fieldName string) bool {
+	for _, name := range ignore {
+		if name == fieldName {
+			return true
+		}
+	}
+	return false
+}
+
+func searchSrc(srcList []src, alias string) *src {
+	for i := range srcList {
+		if srcList[i].Alias == alias {
+			return &srcList[i]
+		}
+	}
+	return nil
+}
+
+// fieldGuard returns the condition the source field must meet to be mapped
+// with the merge strategy, an empty one when it is always mapped.
+func fieldGuard(merge, srcAlias string, srcField field) string {
+	srcRow := fmt.Sprintf("%s.%s", srcAlias, srcField.Name)
+	if merge == mergeFirstNonZero {
+		return nonZeroStr(srcRow, srcField)
+	}
+	if srcField.Ptr {
+		return fmt.Sprintf("%s != nil", srcRow)
+	}
+	return ""
+}
+
+// nonZeroStr returns the condition the field value isn't zero. Structures
+// and arrays of elements other than numbers, strings and booleans are
+// considered never zero.
+func nonZeroStr(srcRow string, srcField field) string {
+	switch {
+	case strings.HasPrefix(srcField.Underlying, "[]"), strings.HasPrefix(srcField.Underlying, "map["):
+		return fmt.Sprintf("len(%s) != 0", srcRow)
+	case srcField.Ptr:
+		return fmt.Sprintf("%s != nil", srcRow)
+	case isComparableArray(srcField.Underlying):
+		useArrayImports(srcField.TypeStr)
+		return fmt.Sprintf("%s != (%s{})", srcRow, srcField.TypeStr)
+	case srcField.Underlying == "string":
+		return fmt.Sprintf("%s != \"\"", srcRow)
+	case srcField.Underlying == "bool":
+		return srcRow
+	}
+	if _, numeric := numericTypeBits[srcField.Underlying]; numeric {
+		return fmt.Sprintf("%s != 0", srcRow)
+	}
+	return ""
+}
+
+// relationGuard checks the sources the relation refers to other than the
+// one it is mapped from for nil when the mapper takes pointers, the rule
+// being only guarded by the block of that source.
+func relationGuard(mapperConfig mapperConfig, srcList []src, srcAlias, srcCastRow string) string {
+	if mapperConfig.InputValue() {
+		return ""
+	}
+	var guards []string
+	for _, srcStruct := range srcList {
+		if srcStruct.Alias != srcAlias && usesIdent(srcCastRow, srcStruct.Alias) {
+			guards = append(guards, fmt.Sprintf("%s != nil", srcStruct.Alias))
+		}
+	}
+	return joinGuards(guards...)
+}
+
 func searchUsedSrc(srcList []src, srcCastRow string) *src {
 	for _, srcStruct := range srcList {
-		if strings.Contains(srcCastRow, srcStruct.Alias) {
+		if usesIdent(srcCastRow, srcStruct.Alias) {
 			return &srcStruct
 		}
 	}
 	return nil
 }
 
+// usesIdent reports whether the expression refers to the identifier, the
+// names it selects and the literals aside.
+func usesIdent(exprStr, name string) bool {
+	expr, err := parser.ParseExpr(exprStr)
+	if err != nil {
+		return false
+	}
+	var found bool
+	var inspect func(node ast.Node) bool
+	inspect = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.SelectorExpr:
+			ast.Inspect(node.X, inspect)
+			return false
+		case *ast.Ident:
+			found = found || node.Name == name
+		}
+		return !found
+	}
+	ast.Inspect(expr, inspect)
+	return found
+}
+
+func structFields(meta *structMeta) []field {
+	scope := typeScope{
+		packageAlias: getPackageAlias(meta.packagePath),
+		types:        meta.types,
+		typeArgs:     meta.typeArgs,
+		imports:      meta.imports,
+	}
+	fields := make([]field, 0, len(meta.fields))
+	for _, f := range meta.fields {
+		fields = append(fields, field{
+			Name:       f.name,
+			Ptr:        isPtr(f.typeAST) || isInterface(f.typeAST, meta.types),
+			TypeStr:    qualifiedTypeStr(f.typeAST, scope),
+			Underlying: underlyingTypeStr(f.typeAST, scope),
+			Tag:        f.tag,
+		})
+	}
+	return fields
+}
+
 func shortPath(meta *structMeta) string {
-	packageAlias := getPackageAlias(meta.packagePath)
-	if len(packageAlias) != 0 {
-		return fmt.Sprintf("%s.%s", packageAlias, meta.name)
-	}
-	return meta.name
-}
-
-func castDstField(srcAlias string, srcField struct {
-	Name    string
-	Ptr     bool
-	TypeStr string
-}, dstField struct {
-	Name    string
-	Ptr     bool
-	TypeStr string
-}) (string, bool) {
-	dstType := dstField.TypeStr
-	srcType := srcField.TypeStr
+	name := meta.name
+	if len(meta.typeArgList) != 0 {
+		name = fmt.Sprintf("%s[%s]", name, strings.Join(meta.typeArgList, ", "))
+	}
+	return qualifiedName(getPackageAlias(meta.packagePath), name)
+}
+
+// qualifiedName qualifies the name with the package alias, names of the
+// package of the generated file having none.
+func qualifiedName(packageAlias, name string) string {
+	if len(packageAlias) == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s.%s", packageAlias, name)
+}
+
+func castDstField(srcAlias string, srcField, dstField field, options castOptions) (string, bool) {
 	srcRow := fmt.Sprintf("%s.%s", srcAlias, srcField.Name)
+	if castStr, ok := castRow(srcRow, srcField.TypeStr, dstField.TypeStr, options); ok {
+		return castStr, true
+	}
+	if isEmptyInterface(dstField.TypeStr) {
+		return srcRow, true
+	}
+	return castNamedType(srcRow, srcField, dstField, options)
+}
+
+// castNamedType converts between named types and their underlying types.
+// Distinct named types are only converted into each other when
+// options.convertNamedTypes is set.
+func castNamedType(srcRow string, srcField, dstField field, options castOptions) (string, bool) {
+	srcNamed := srcField.TypeStr != srcField.Underlying
+	dstNamed := dstField.TypeStr != dstField.Underlying
+	if !srcNamed && !dstNamed {
+		return srcRow, false
+	}
+	if srcNamed && dstNamed && !options.convertNamedTypes {
+		return srcRow, false
+	}
+	srcSlice := strings.HasPrefix(srcField.TypeStr, "[]")
+	dstSlice := strings.HasPrefix(dstField.TypeStr, "[]")
+	if srcSlice != dstSlice {
+		return srcRow, false
+	}
+	if !srcSlice {
+		return castNamedValue(srcRow, srcField.TypeStr, srcField.Underlying, dstField.TypeStr, dstField.Underlying, options)
+	}
+	srcElem, dstElem := strings.TrimPrefix(srcField.TypeStr, "[]"), strings.TrimPrefix(dstField.TypeStr, "[]")
+	if !options.inlineHelpers && strings.HasPrefix(srcElem, "*") {
+		srcRow = fmt.Sprintf("%s.DerefSlice(%s)", useImport("mapping", mappingPackage), srcRow)
+		srcElem = strings.TrimPrefix(srcElem, "*")
+		srcField.Underlying = "[]" + strings.TrimPrefix(srcField.Underlying, "[]*")
+	}
+	castStr, ok := castNamedValue("v",
+		srcElem, strings.TrimPrefix(srcField.Underlying, "[]"),
+		dstElem, strings.TrimPrefix(dstField.Underlying, "[]"), options)
+	if !ok {
+		return srcRow, false
+	}
+	if !options.inlineHelpers {
+		return mapSliceStr(srcRow, srcElem, dstElem, castStr), true
+	}
+	assign := fmt.Sprintf("d[i] = %s", castStr)
+	if strings.HasPrefix(srcElem, "*") {
+		assign = fmt.Sprintf("if v != nil { %s }", assign)
+	}
+	return fmt.Sprintf("func(s []%s) []%s { d := make([]%s, len(s)); for i, v := range s { %s }; return d }(%s)",
+		srcElem, dstElem, dstElem, assign, srcRow), true
+}
+
+// castNamedValue converts a value or a pointer of a named type through the
+// underlying types of both sides.
+func castNamedValue(srcRow, srcType, srcUnderlying, dstType, dstUnderlying string, options castOptions) (string, bool) {
+	srcBase, srcBaseUnderlying := strings.TrimPrefix(srcType, "*"), strings.TrimPrefix(srcUnderlying, "*")
+	dstBase, dstBaseUnderlying := strings.TrimPrefix(dstType, "*"), strings.TrimPrefix(dstUnderlying, "*")
+	if strings.HasPrefix(srcBaseUnderlying, "*") || strings.HasPrefix(dstBaseUnderlying, "*") ||
+		strings.HasPrefix(srcBaseUnderlying, "[]") || strings.HasPrefix(dstBaseUnderlying, "[]") {
+		return srcRow, false
+	}
+	value := srcRow
+	if strings.HasPrefix(srcType, "*") {
+		value = "*" + value
+	}
+	if srcBase != srcBaseUnderlying {
+		value = fmt.Sprintf("%s(%s)", srcBaseUnderlying, value)
+	}
+	castStr, ok := castRow(value, srcBaseUnderlying, dstBaseUnderlying, options)
+	if !ok {
+		return srcRow, false
+	}
+	switch {
+	case strings.HasPrefix(dstType, "*") && dstBase != dstBaseUnderlying:
+		return fmt.Sprintf("(*%s)(%s)", dstBase, ptrStr(dstBaseUnderlying, castStr, options)), true
+	case strings.HasPrefix(dstType, "*"):
+		return ptrStr(dstBaseUnderlying, castStr, options), true
+	case dstBase != dstBaseUnderlying:
+		return fmt.Sprintf("%s(%s)", dstBase, castStr), true
+	}
+	return castStr, true
+}
+
+func castRow(srcRow, srcType, dstType string, options castOptions) (string, bool) {
 	if dstType == srcType {
 		return srcRow, true
 	}
 	if dstType != srcType {
+		if castStr, ok := castWellKnownType(srcRow, srcType, dstType, options); ok {
+			return castStr, true
+		}
+		if castStr, ok := castArray(srcRow, srcType, dstType, options); ok {
+			return castStr, true
+		}
 		if "*"+dstType == srcType {
 			srcRow = "*" + srcRow
 			return srcRow, true
@@ -504,12 +1777,12 @@ func castDstField(srcAlias string, srcField struct {
 			case "*bool":
 				switch srcType {
 				case "bool":
-					return fmt.Sprintf("%sPtr(%s)", strings.Trim(dstType, "*"), srcRow), true
+					return ptrStr(strings.Trim(dstType, "*"), srcRow, options), true
 				}
 			case "*string":
 				switch srcType {
 				case "string":
-					return fmt.Sprintf("%sPtr(%s)", strings.Trim(dstType, "*"), srcRow), true
+					return ptrStr(strings.Trim(dstType, "*"), srcRow, options), true
 				}
 			case "byte",
 				"uint", "uint8", "uint16", "uint32", "uint64",
@@ -520,7 +1793,7 @@ func castDstField(srcAlias string, srcField struct {
 					"uint", "uint8", "uint16", "uint32", "uint64",
 					"int", "int8", "int16", "int32", "int64",
 					"float32", "float64":
-					return fmt.Sprintf("%s(%s(%s))", dstType, dstType, srcRow), true
+					return fmt.Sprintf("%s(%s)", dstType, srcRow), true
 				case "*byte",
 					"*uint", "*uint8", "*uint16", "*uint32", "*uint64",
 					"*int", "*int8", "*int16", "*int32", "*int64",
@@ -536,12 +1809,12 @@ func castDstField(srcAlias string, srcField struct {
 					"uint", "uint8", "uint16", "uint32", "uint64",
 					"int", "int8", "int16", "int32", "int64",
 					"float32", "float64":
-					return fmt.Sprintf("%sPtr(%s(%s))", strings.Trim(dstType, "*"), strings.Trim(dstType, "*"), srcRow), true
+					return ptrStr(strings.Trim(dstType, "*"), fmt.Sprintf("%s(%s)", strings.Trim(dstType, "*"), srcRow), options), true
 				case "*byte",
 					"*uint", "*uint8", "*uint16", "*uint32", "*uint64",
 					"*int", "*int8", "*int16", "*int32", "*int64",
 					"*float32", "*float64":
-					return fmt.Sprintf("%s(%s(%s))", strings.Trim(dstType, "*"), strings.Trim(dstType, "*"), srcRow), true
+					return ptrStr(strings.Trim(dstType, "*"), fmt.Sprintf("%s(*%s)", strings.Trim(dstType, "*"), srcRow), options), true
 				}
 			case "[]bool", "[]string", "[]byte",
 				"[]uint", "[]uint8", "[]uint16", "[]uint32", "[]uint64",
@@ -552,12 +1825,12 @@ func castDstField(srcAlias string, srcField struct {
 					"[]uint", "[]uint8", "[]uint16", "[]uint32", "[]uint64",
 					"[]int", "[]int8", "[]int16", "[]int32", "[]int64",
 					"[]float32", "[]float64":
-					return fmt.Sprintf("%sArrTo%sArr(%s)", strings.Trim(srcType, "[]"), strings.Title(strings.Trim(dstType, "[]")), srcRow), true
+					return castSlice(srcRow, srcType, dstType, options)
 				case "[]*bool", "[]*string", "[]*byte",
 					"[]*uint", "[]*uint8", "[]*uint16", "[]*uint32", "[]*uint64",
 					"[]*int", "[]*int8", "[]*int16", "[]*int32", "[]*int64",
 					"[]*float32", "[]*float64":
-					return fmt.Sprintf("%sPtrArrTo%sArr(%s)", strings.Trim(srcType, "[]*"), strings.Title(strings.Trim(dstType, "[]")), srcRow), true
+					return castSlice(srcRow, srcType, dstType, options)
 				}
 			case "[]*bool", "[]*string", "[]*byte",
 				"[]*uint", "[]*uint8", "[]*uint16", "[]*uint32", "[]*uint64",
@@ -568,12 +1841,12 @@ func castDstField(srcAlias string, srcField struct {
 					"[]uint", "[]uint8", "[]uint16", "[]uint32", "[]uint64",
 					"[]int", "[]int8", "[]int16", "[]int32", "[]int64",
 					"[]float32", "[]float64":
-					return fmt.Sprintf("%sArrTo%sPtrArr(%s)", strings.Trim(srcType, "[]"), strings.Title(strings.Trim(dstType, "[]*")), srcRow), true
+					return castSlice(srcRow, srcType, dstType, options)
 				case "[]*bool", "[]*string", "[]*byte",
 					"[]*uint", "[]*uint8", "[]*uint16", "[]*uint32", "[]*uint64",
 					"[]*int", "[]*int8", "[]*int16", "[]*int32", "[]*int64",
 					"[]*float32", "[]*float64":
-					return fmt.Sprintf("%sPtrArrTo%sPtrArr(%s)", strings.Trim(srcType, "[]*"), strings.Title(strings.Trim(dstType, "[]*")), srcRow), true
+					return castSlice(srcRow, srcType, dstType, options)
 				}
 			}
 		}
@@ -584,13 +1857,15 @@ func castDstField(srcAlias string, srcField struct {
 func isPtr(expr ast.Expr) bool {
 	switch t := expr.(type) {
 	case *ast.ArrayType:
-		return true
+		return t.Len == nil
 	case *ast.MapType:
 		return true
 	case *ast.SelectorExpr:
 		return isPtr(t.X)
 	case *ast.StarExpr:
 		return true
+	case *ast.InterfaceType:
+		return true
 	case *ast.Ident:
 		return false
 	default:
@@ -602,21 +1877,131 @@ func isPtr(expr ast.Expr) bool {
 func typeStrValue(node ast.Expr) string {
 	switch t := node.(type) {
 	case *ast.ArrayType:
+		if t.Len != nil {
+			return fmt.Sprintf("[%s]%s", arrayLenStr(t.Len, ""), typeStrValue(t.Elt))
+		}
 		return fmt.Sprintf("[]%s", typeStrValue(t.Elt))
 	case *ast.MapType:
 		return fmt.Sprintf("map[%s]%s", typeStrValue(t.Key), typeStrValue(t.Value))
 	case *ast.SelectorExpr:
-		return typeStrValue(t.X)
+		return fmt.Sprintf("%s.%s", typeStrValue(t.X), t.Sel.Name)
 	case *ast.StarExpr:
 		return fmt.Sprintf("*%s", typeStrValue(t.X))
 	case *ast.Ident:
 		return t.Name
+	case *ast.IndexExpr:
+		return fmt.Sprintf("%s[%s]", typeStrValue(t.X), typeStrValue(t.Index))
+	case *ast.IndexListExpr:
+		indices := make([]string, 0, len(t.Indices))
+		for _, index := range t.Indices {
+			indices = append(indices, typeStrValue(index))
+		}
+		return fmt.Sprintf("%s[%s]", typeStrValue(t.X), strings.Join(indices, ", "))
+	case *ast.InterfaceType:
+		return interfaceStr(t)
 	default:
 		//log.Printf("Unknown %T type", t)
 	}
 	return ""
 }
 
+// predeclaredTypes are the type names never qualified with a package alias.
+var predeclaredTypes = map[string]bool{
+	"any": true, "bool": true, "byte": true, "comparable": true, "error": true, "rune": true, "string": true,
+	"complex64": true, "complex128": true, "float32": true, "float64": true, "uintptr": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+}
+
+// typeScope resolves the identifiers found in the field types of a structure.
+type typeScope struct {
+	packageAlias string
+	types        map[string]*ast.TypeSpec
+	// typeArgs maps the type parameters of a generic structure to the
+	// qualified type arguments it is instantiated with.
+	typeArgs map[string]string
+	// imports are the packages the file declaring the structure imports by
+	// name, the types of other packages being spelled with the aliases
+	// reserved to them.
+	imports map[string]string
+}
+
+// qualifiedTypeStr renders the type as referenced from the generated file:
+// types declared in the structure package are prefixed with its alias, type
+// aliases are replaced by the aliased type and type parameters by the type
+// arguments.
+func qualifiedTypeStr(node ast.Expr, scope typeScope) string {
+	switch t := node.(type) {
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return fmt.Sprintf("[%s]%s", arrayLenStr(t.Len, scope.packageAlias), qualifiedTypeStr(t.Elt, scope))
+		}
+		return fmt.Sprintf("[]%s", qualifiedTypeStr(t.Elt, scope))
+	case *ast.MapType:
+		return fmt.Sprintf("map[%s]%s", qualifiedTypeStr(t.Key, scope), qualifiedTypeStr(t.Value, scope))
+	case *ast.StarExpr:
+		return fmt.Sprintf("*%s", qualifiedTypeStr(t.X, scope))
+	case *ast.SelectorExpr:
+		if packageIdent, ok := t.X.(*ast.Ident); ok {
+			if importPath, exist := scope.imports[packageIdent.Name]; exist {
+				return qualifiedName(reservePackageAlias(importPath), t.Sel.Name)
+			}
+		}
+	case *ast.IndexExpr:
+		return fmt.Sprintf("%s[%s]", qualifiedTypeStr(t.X, scope), qualifiedTypeStr(t.Index, scope))
+	case *ast.IndexListExpr:
+		indices := make([]string, 0, len(t.Indices))
+		for _, index := range t.Indices {
+			indices = append(indices, qualifiedTypeStr(index, scope))
+		}
+		return fmt.Sprintf("%s[%s]", qualifiedTypeStr(t.X, scope), strings.Join(indices, ", "))
+	case *ast.Ident:
+		if typeArg, exist := scope.typeArgs[t.Name]; exist {
+			return typeArg
+		}
+		if predeclaredTypes[t.Name] {
+			return t.Name
+		}
+		if ts, exist := scope.types[t.Name]; exist && ts.Assign.IsValid() {
+			return qualifiedTypeStr(ts.Type, scope)
+		}
+		if len(scope.packageAlias) != 0 {
+			return fmt.Sprintf("%s.%s", scope.packageAlias, t.Name)
+		}
+		return t.Name
+	}
+	return typeStrValue(node)
+}
+
+// underlyingTypeStr renders the type with every named type declared in the
+// structure package replaced by its underlying type. Named structures and
+// interfaces are kept as they are.
+func underlyingTypeStr(node ast.Expr, scope typeScope) string {
+	switch t := node.(type) {
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return fmt.Sprintf("[%s]%s", arrayLenStr(t.Len, scope.packageAlias), underlyingTypeStr(t.Elt, scope))
+		}
+		return fmt.Sprintf("[]%s", underlyingTypeStr(t.Elt, scope))
+	case *ast.MapType:
+		return fmt.Sprintf("map[%s]%s", underlyingTypeStr(t.Key, scope), underlyingTypeStr(t.Value, scope))
+	case *ast.StarExpr:
+		return fmt.Sprintf("*%s", underlyingTypeStr(t.X, scope))
+	case *ast.Ident:
+		if _, exist := scope.typeArgs[t.Name]; exist {
+			break
+		}
+		if ts, exist := scope.types[t.Name]; exist && ts.TypeParams == nil {
+			switch ts.Type.(type) {
+			case *ast.StructType, *ast.InterfaceType:
+			default:
+				return underlyingTypeStr(ts.Type, scope)
+			}
+		}
+	}
+	return qualifiedTypeStr(node, scope)
+}
+
 func parseRelation(relation string) (dstAlias, dstField, srcCastRow string) {
 	res := strings.Split(relation, ":")
 	if len(res) != 2 {
@@ -641,35 +2026,37 @@ func mapperFilePackage(mapperFilePath string) string {
 	return filepath.Base(filepath.Dir(absPath))
 }
 
+// mapperFileImportPath returns the import path of the package of the
+// generated file, spelled as the one of the parsed structures.
+func mapperFileImportPath(mapperFilePath string) string {
+	absPath, err := filepath.Abs(mapperFilePath)
+	if err != nil {
+		panic(err)
+	}
+	return parseImportPackagePath(absPath)
+}
+
 func parseStructure(dir, path string) (*structMeta, error) {
 	switch path {
+	case dynamicMapPath:
+		return &structMeta{name: dynamicMapType}, nil
 	case "byte", "bool", "string",
 		"uint", "uint8", "uint16", "uint32", "uint64",
 		"int", "int8", "int16", "int32", "int64",
 		"float32", "float64":
 		return &structMeta{name: path}, nil
 	}
+	path, typeArgPaths := splitTypeArgs(path)
 	i := strings.LastIndex(path, ".")
 	if i <= 0 {
 		return nil, fmt.Errorf("source path \"%s\" incorrect", path)
 	}
-	filePath := path[:i] + ".go"
 	structName := path[i+1:]
 
 	fileSet := token.NewFileSet()
-	if !strings.HasSuffix(filePath, ".go") {
-		filePath = filePath + ".go"
-	}
-	data, err := ioutil.ReadFile(pathUtil.Join(dir, filePath))
+	data, fileLocation, filePath, err := readSourceFile(dir, path[:i]+".go")
 	if err != nil {
-		data, err = ioutil.ReadFile(filepath.Join(os.Getenv("GOPATH"), "src", filePath))
-		if err != nil {
-			return nil, fmt.Errorf("incorrect file path %s", filePath)
-		}
-	} else {
-		if packagePath, err := filepath.Abs(pathUtil.Join(dir, filePath)); err == nil {
-			filePath = strings.TrimPrefix(packagePath, filepath.Join(os.Getenv("GOPATH"), "src", string(os.PathSeparator)))
-		}
+		return nil, err
 	}
 	fileAST, err := parser.ParseFile(fileSet, "", data, 0)
 	if err != nil {
@@ -692,16 +2079,26 @@ func parseStructure(dir, path string) (*structMeta, error) {
 	if o, exist := fileAST.Scope.Objects[structName]; exist {
 		if ts, ok := o.Decl.(*ast.TypeSpec); ok {
 			res = &structMeta{
-				name:        ts.Name.Name,
-				packagePath: parseImportPackagePath(filePath),
+				name:         ts.Name.Name,
+				packagePath:  parseImportPackagePath(filePath),
+				types:        parsePackageTypes(filepath.Dir(fileLocation)),
+				fileLocation: fileLocation,
+				imports:      fileImports(fileAST),
+			}
+			if err := instantiateStructure(res, ts, dir, typeArgPaths); err != nil {
+				return nil, err
 			}
 			if s, ok := ts.Type.(*ast.StructType); ok {
+				protoMessage := isProtoMessage(s)
 				fields := make([]fieldMeta, 0, len(s.Fields.List))
 				for i := range s.Fields.List {
 					f := s.Fields.List[i]
 					if len(f.Names) == 0 {
 						continue
 					}
+					if protoMessage && protoInternalFields[f.Names[0].Name] {
+						continue
+					}
 					field := struct {
 						name    string
 						tag     string
@@ -735,13 +2132,129 @@ type structMeta struct {
 	name        string
 	packagePath string
 	fields      []fieldMeta
+	types       map[string]*ast.TypeSpec
+	// typeArgs and typeArgList hold the qualified type arguments of an
+	// instantiated generic structure.
+	typeArgs    map[string]string
+	typeArgList []string
+	// fileLocation is the file declaring the structure, imports are the
+	// packages it imports by name.
+	fileLocation string
+	imports      map[string]string
+}
+
+// readSourceFile reads the file relative to the config directory, falling
+// back to GOPATH/src. packageFilePath is the path of the file relative to
+// GOPATH/src, its import path, when the file is found there.
+func readSourceFile(dir, filePath string) (data []byte, fileLocation, packageFilePath string, err error) {
+	packageFilePath = filePath
+	fileLocation = pathUtil.Join(dir, filePath)
+	data, err = ioutil.ReadFile(fileLocation)
+	if err != nil {
+		fileLocation = filepath.Join(os.Getenv("GOPATH"), "src", filePath)
+		data, err = ioutil.ReadFile(fileLocation)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("incorrect file path %s", filePath)
+		}
+	} else {
+		if packagePath, err := filepath.Abs(fileLocation); err == nil {
+			packageFilePath = strings.TrimPrefix(packagePath, filepath.Join(os.Getenv("GOPATH"), "src")+string(os.PathSeparator))
+		}
+	}
+	return data, fileLocation, packageFilePath, nil
+}
+
+// instantiateStructure resolves the type arguments a generic structure is
+// instantiated with, they are given as paths as well.
+func instantiateStructure(meta *structMeta, ts *ast.TypeSpec, dir string, typeArgPaths []string) error {
+	var typeParams []string
+	if ts.TypeParams != nil {
+		for _, param := range ts.TypeParams.List {
+			for _, name := range param.Names {
+				typeParams = append(typeParams, name.Name)
+			}
+		}
+	}
+	if len(typeParams) != len(typeArgPaths) {
+		return fmt.Errorf("structure %s has %d type parameters, %d type arguments given", ts.Name.Name, len(typeParams), len(typeArgPaths))
+	}
+	if len(typeParams) == 0 {
+		return nil
+	}
+	meta.typeArgs = make(map[string]string, len(typeParams))
+	for i, typeArgPath := range typeArgPaths {
+		typeArgMeta, err := parseStructure(dir, typeArgPath)
+		if err != nil {
+			return err
+		}
+		meta.typeArgs[typeParams[i]] = shortPath(typeArgMeta)
+		meta.typeArgList = append(meta.typeArgList, shortPath(typeArgMeta))
+	}
+	return nil
+}
+
+// splitTypeArgs splits "path.Page[path.User, string]" into the structure
+// path and the type argument paths.
+func splitTypeArgs(path string) (string, []string) {
+	i := strings.Index(path, "[")
+	if i <= 0 || !strings.HasSuffix(path, "]") {
+		return path, nil
+	}
+	var typeArgs []string
+	var depth, start int
+	inner := path[i+1 : len(path)-1]
+	for j, r := range inner {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				typeArgs = append(typeArgs, strings.TrimSpace(inner[start:j]))
+				start = j + 1
+			}
+		}
+	}
+	typeArgs = append(typeArgs, strings.TrimSpace(inner[start:]))
+	return path[:i], typeArgs
+}
+
+// parsePackageTypes collects the type declarations of every file of the
+// package located in dir.
+func parsePackageTypes(dir string) map[string]*ast.TypeSpec {
+	types := make(map[string]*ast.TypeSpec, 10)
+	fileSet := token.NewFileSet()
+	packages, err := parser.ParseDir(fileSet, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return types
+	}
+	for _, p := range packages {
+		for _, f := range p.Files {
+			for _, decl := range f.Decls {
+				genDecl, ok := decl.(*ast.GenDecl)
+				if !ok || genDecl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range genDecl.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						types[ts.Name.Name] = ts
+					}
+				}
+			}
+		}
+	}
+	return types
 }
 
 func parseImportPackagePath(sourcePath string) string {
-	return filepath.Dir(strings.TrimLeft(sourcePath, filepath.Join(os.Getenv("GOPATH"), "src")))
+	return filepath.Dir(strings.TrimPrefix(sourcePath, filepath.Join(os.Getenv("GOPATH"), "src")+string(os.PathSeparator)))
 }
 
 func parsePackageAndStructure(srcPath string) (string, string, error) {
+	srcPath, _ = splitTypeArgs(srcPath)
 	i := strings.LastIndex(srcPath, ".")
 	if i <= 0 || i+1 >= len(srcPath) {
 		return "", "", fmt.Errorf("src path \"%s\" incorrect format", srcPath)
@@ -749,27 +2262,54 @@ func parsePackageAndStructure(srcPath string) (string, string, error) {
 	return srcPath[:i], srcPath[i+1:], nil
 }
 
+// useImport registers a package referenced by generated code under its
+// conventional name and returns that name.
+func useImport(alias, importPackagePath string) string {
+	importPackageAliasMap[alias] = importPackagePath
+	return alias
+}
+
+// getPackageAlias returns the alias the generated file imports the package
+// by, registering the import.
 func getPackageAlias(importPackagePath string) string {
+	alias := reservePackageAlias(importPackagePath)
+	if len(alias) != 0 && alias != importPackagePath {
+		importPackageAliasMap[alias] = importPackagePath
+	}
+	return alias
+}
+
+// reservePackageAlias returns the alias the generated file refers to the
+// package by without importing it, the first free one derived from its
+// path being reserved to it. The types of the structure fields are spelled
+// with it, so types of distinct packages sharing a name stay distinct. The
+// package of the generated file has none.
+func reservePackageAlias(importPackagePath string) string {
 	if strings.HasSuffix(importPackagePath, ".go") {
 		importPackagePath = strings.TrimRight(importPackagePath, ".go")
 	}
+	if len(outPackagePath) != 0 && importPackagePath == outPackagePath {
+		return ""
+	}
 	i := strings.LastIndex(importPackagePath, "/")
 	if i <= 0 || i+1 >= len(importPackagePath) {
 		return importPackagePath
 	}
 	defaultAlias := importPackagePath[i+1:]
+	if j := strings.LastIndex(importPackagePath[:i], "/"); isMajorVersion(defaultAlias) && j >= 0 {
+		defaultAlias = importPackagePath[j+1 : i]
+	}
 	alias := defaultAlias
-	i = 1
-	v, exist := importPackageAliasMap[alias]
-	for exist {
-		if v == importPackagePath {
-			exist = false
-		} else {
-			alias = defaultAlias + strconv.Itoa(i)
-			i++
-			v, exist = importPackageAliasMap[alias]
+	for i = 1; ; i++ {
+		path, exist := importPackageAliasMap[alias]
+		if !exist {
+			path, exist = reservedPackageAliasMap[alias]
+		}
+		if !exist || path == importPackagePath {
+			break
 		}
+		alias = defaultAlias + strconv.Itoa(i)
 	}
-	importPackageAliasMap[alias] = importPackagePath
+	reservedPackageAliasMap[alias] = importPackagePath
 	return alias
 }
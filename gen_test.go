@@ -5,8 +5,20 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"text/template"
+	"time"
 )
 
 func Test_searchUsedField(t *testing.T) {
@@ -186,3 +198,1925 @@ func Test_searchUsedField(t *testing.T) {
 		})
 	}
 }
+
+func Test_castWellKnownType(t *testing.T) {
+	type args struct {
+		srcRow  string
+		srcType string
+		dstType string
+	}
+	tests := []struct {
+		name   string
+		args   args
+		want   string
+		wantOk bool
+	}{
+		{
+			name:   "Wrapper to value",
+			args:   args{srcRow: "src.Name", srcType: "*wrapperspb.StringValue", dstType: "string"},
+			want:   "src.Name.GetValue()",
+			wantOk: true,
+		},
+		{
+			name:   "Wrapper to pointer",
+			args:   args{srcRow: "src.Name", srcType: "*wrapperspb.StringValue", dstType: "*string"},
+			want:   "mapping.Ptr(src.Name.GetValue())",
+			wantOk: true,
+		},
+		{
+			name:   "Pointer to wrapper",
+			args:   args{srcRow: "src.Age", srcType: "*int64", dstType: "*wrapperspb.Int64Value"},
+			want:   "wrapperspb.Int64(*src.Age)",
+			wantOk: true,
+		},
+		{
+			name:   "Timestamp to time",
+			args:   args{srcRow: "src.CreatedAt", srcType: "*timestamppb.Timestamp", dstType: "time.Time"},
+			want:   "src.CreatedAt.AsTime()",
+			wantOk: true,
+		},
+		{
+			name:   "Struct to map",
+			args:   args{srcRow: "src.Meta", srcType: "*structpb.Struct", dstType: "map[string]any"},
+			want:   "src.Meta.AsMap()",
+			wantOk: true,
+		},
+		{
+			name:   "Map to struct",
+			args:   args{srcRow: "src.Meta", srcType: "map[string]any", dstType: "*structpb.Struct"},
+			want:   "src.Meta",
+			wantOk: false,
+		},
+		{
+			name:   "Incompatible wrapper",
+			args:   args{srcRow: "src.Name", srcType: "*wrapperspb.StringValue", dstType: "bool"},
+			want:   "src.Name.GetValue()",
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotOk := castWellKnownType(tt.args.srcRow, tt.args.srcType, tt.args.dstType, castOptions{})
+			if got != tt.want || gotOk != tt.wantOk {
+				t.Errorf("castWellKnownType() = %v, %v, want %v, %v", got, gotOk, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_castNamedType(t *testing.T) {
+	type args struct {
+		srcField field
+		dstField field
+		options  castOptions
+	}
+	tests := []struct {
+		name   string
+		args   args
+		want   string
+		wantOk bool
+	}{
+		{
+			name: "Named to underlying",
+			args: args{
+				srcField: field{Name: "ID", TypeStr: "model.UserID", Underlying: "int64"},
+				dstField: field{Name: "ID", TypeStr: "int64", Underlying: "int64"},
+			},
+			want:   "int64(src.ID)",
+			wantOk: true,
+		},
+		{
+			name: "Underlying to named pointer",
+			args: args{
+				srcField: field{Name: "ID", TypeStr: "int64", Underlying: "int64"},
+				dstField: field{Name: "ID", Ptr: true, TypeStr: "*model.UserID", Underlying: "*int64"},
+			},
+			want:   "(*model.UserID)(mapping.Ptr(src.ID))",
+			wantOk: true,
+		},
+		{
+			name: "Named pointer to underlying",
+			args: args{
+				srcField: field{Name: "Email", Ptr: true, TypeStr: "*model.Email", Underlying: "*string"},
+				dstField: field{Name: "Email", TypeStr: "string", Underlying: "string"},
+			},
+			want:   "string(*src.Email)",
+			wantOk: true,
+		},
+		{
+			name: "Named slice to underlying slice",
+			args: args{
+				srcField: field{Name: "IDs", Ptr: true, TypeStr: "[]model.UserID", Underlying: "[]int64"},
+				dstField: field{Name: "IDs", Ptr: true, TypeStr: "[]int64", Underlying: "[]int64"},
+			},
+			want:   "mapping.MapSlice(src.IDs, func(v model.UserID) int64 { return int64(v) })",
+			wantOk: true,
+		},
+		{
+			name: "Distinct named types without opt-in",
+			args: args{
+				srcField: field{Name: "ID", TypeStr: "model.UserID", Underlying: "int64"},
+				dstField: field{Name: "ID", TypeStr: "pb.UserID", Underlying: "int64"},
+			},
+			want:   "src.ID",
+			wantOk: false,
+		},
+		{
+			name: "Distinct named types with opt-in",
+			args: args{
+				srcField: field{Name: "ID", TypeStr: "model.UserID", Underlying: "int64"},
+				dstField: field{Name: "ID", TypeStr: "pb.UserID", Underlying: "int64"},
+				options:  castOptions{convertNamedTypes: true},
+			},
+			want:   "pb.UserID(int64(src.ID))",
+			wantOk: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotOk := castNamedType("src."+tt.args.srcField.Name, tt.args.srcField, tt.args.dstField, tt.args.options)
+			if got != tt.want || gotOk != tt.wantOk {
+				t.Errorf("castNamedType() = %v, %v, want %v, %v", got, gotOk, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_isNarrowing(t *testing.T) {
+	tests := []struct {
+		srcType string
+		dstType string
+		want    bool
+	}{
+		{srcType: "int64", dstType: "int8", want: true},
+		{srcType: "int8", dstType: "int64", want: false},
+		{srcType: "int", dstType: "int64", want: false},
+		{srcType: "int64", dstType: "int", want: true},
+		{srcType: "int", dstType: "uint64", want: true},
+		{srcType: "uint32", dstType: "int64", want: false},
+		{srcType: "uint64", dstType: "int64", want: true},
+		{srcType: "float32", dstType: "int64", want: true},
+		{srcType: "int64", dstType: "float32", want: false},
+		{srcType: "float64", dstType: "float32", want: true},
+		{srcType: "float32", dstType: "float64", want: false},
+		{srcType: "string", dstType: "int", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.srcType+" to "+tt.dstType, func(t *testing.T) {
+			if got := isNarrowing(tt.srcType, tt.dstType); got != tt.want {
+				t.Errorf("isNarrowing() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_castSlice(t *testing.T) {
+	tests := []struct {
+		name    string
+		inline  bool
+		srcType string
+		dstType string
+		want    string
+		wantOk  bool
+	}{
+		{name: "Convert", srcType: "[]int", dstType: "[]int64", want: "mapping.ConvertSlice[int, int64](src.F)", wantOk: true},
+		{name: "To pointers", srcType: "[]string", dstType: "[]*string", want: "mapping.PtrSlice(src.F)", wantOk: true},
+		{name: "From pointers", srcType: "[]*uint32", dstType: "[]int16", want: "mapping.ConvertSlice[uint32, int16](mapping.DerefSlice(src.F))", wantOk: true},
+		{name: "Pointers", srcType: "[]*int", dstType: "[]*float64", want: "mapping.MapSlice(src.F, mapping.ConvertPtr[int, float64])", wantOk: true},
+		{name: "Not convertible", srcType: "[]bool", dstType: "[]int", want: "src.F", wantOk: false},
+		{name: "Inline", inline: true, srcType: "[]uint32", dstType: "[]*int16", want: "uint32ArrToInt16PtrArr(src.F)", wantOk: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotOk := castSlice("src.F", tt.srcType, tt.dstType, castOptions{inlineHelpers: tt.inline})
+			if got != tt.want || gotOk != tt.wantOk {
+				t.Errorf("castSlice() = %v, %v, want %v, %v", got, gotOk, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_splitTypeArgs(t *testing.T) {
+	tests := []struct {
+		path         string
+		wantPath     string
+		wantTypeArgs []string
+	}{
+		{path: "model/user.User", wantPath: "model/user.User"},
+		{path: "model/page.Page[pb/user.User]", wantPath: "model/page.Page", wantTypeArgs: []string{"pb/user.User"}},
+		{path: "model/page.Result[model/page.Page[int], string]", wantPath: "model/page.Result", wantTypeArgs: []string{"model/page.Page[int]", "string"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			gotPath, gotTypeArgs := splitTypeArgs(tt.path)
+			if gotPath != tt.wantPath || !reflect.DeepEqual(gotTypeArgs, tt.wantTypeArgs) {
+				t.Errorf("splitTypeArgs() = %v, %v, want %v, %v", gotPath, gotTypeArgs, tt.wantPath, tt.wantTypeArgs)
+			}
+		})
+	}
+}
+
+func Test_castNestedField(t *testing.T) {
+	options := castOptions{
+		mappers: map[[2]string]nestedMapper{
+			{"pb.User", "model.User"}:   {MapperFuncName: "UserMapper", ListMapperFuncName: "UserListMapper"},
+			{"pb.Team", "model.Team"}:   {MapperFuncName: "TeamMapper", ListMapperFuncName: "TeamListMapper", ReturnsError: true},
+			{"pb.Order", "model.Order"}: {MapperFuncName: "OrderMapper", ListMapperFuncName: "OrderListMapper", InputValue: true, OutputValue: true, ListInputValue: true, ListOutputValue: true},
+			{"pb.Line", "model.Line"}:   {MapperFuncName: "LineMapper", ListMapperFuncName: "LineListMapper", ListInputValue: true, ListOutputValue: true},
+		},
+	}
+	tests := []struct {
+		name         string
+		srcType      string
+		dstType      string
+		returnsError bool
+		want         string
+		wantChecked  bool
+		wantCastAddr bool
+		wantOk       bool
+	}{
+		{name: "Pointer", srcType: "*pb.User", dstType: "*model.User", want: "UserMapper(src.F)", wantOk: true},
+		{name: "Value", srcType: "pb.User", dstType: "model.User", want: "mapping.Deref(UserMapper(&src.F))", wantOk: true},
+		{name: "List", srcType: "[]*pb.User", dstType: "[]*model.User", want: "UserListMapper(src.F)", wantOk: true},
+		{name: "Map", srcType: "map[string]*pb.User", dstType: "map[string]*model.User", want: "mapping.MapMap(src.F, UserMapper)", wantOk: true},
+		{name: "Error returning mapper", srcType: "*pb.Team", dstType: "*model.Team", want: "src.F", wantOk: false},
+		{name: "Error returning mapper of value", srcType: "pb.Team", dstType: "*model.Team", returnsError: true, want: "TeamMapper(&src.F)", wantChecked: true, wantOk: true},
+		{name: "Value mapper", srcType: "pb.Order", dstType: "model.Order", want: "OrderMapper(src.F)", wantOk: true},
+		{name: "Value mapper into pointer", srcType: "pb.Order", dstType: "*model.Order", want: "mapping.Ptr(OrderMapper(src.F))", wantOk: true},
+		{name: "Value mapper of pointer", srcType: "*pb.Order", dstType: "*model.Order", want: "src.F", wantOk: false},
+		{name: "Value mapper list", srcType: "[]pb.Order", dstType: "[]model.Order", want: "OrderListMapper(src.F)", wantOk: true},
+		{name: "Value list of pointer mapper", srcType: "[]pb.Line", dstType: "[]model.Line", want: "LineListMapper(src.F)", wantOk: true},
+		{name: "Pointer list of value list mapper", srcType: "[]*pb.Line", dstType: "[]*model.Line", want: "mapping.MapSlice(src.F, func(v *pb.Line) *model.Line { return LineMapper(v) })", wantOk: true},
+		{name: "Value mapper map", srcType: "map[string]pb.Order", dstType: "map[string]*model.Order", want: "mapping.MapMap(src.F, func(v pb.Order) *model.Order { return mapping.Ptr(OrderMapper(v)) })", wantOk: true},
+		{name: "Unknown types", srcType: "*pb.Item", dstType: "*model.Item", want: "src.F", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options.returnsError = tt.returnsError
+			got, gotChecked, gotCastAddr, gotOk := castNestedField("src", field{Name: "F", TypeStr: tt.srcType}, field{Name: "F", TypeStr: tt.dstType}, options)
+			if got != tt.want || gotChecked != tt.wantChecked || gotCastAddr != tt.wantCastAddr || gotOk != tt.wantOk {
+				t.Errorf("castNestedField() = %v, %v, %v, %v, want %v, %v, %v, %v", got, gotChecked, gotCastAddr, gotOk, tt.want, tt.wantChecked, tt.wantCastAddr, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_fieldGuard(t *testing.T) {
+	tests := []struct {
+		name     string
+		merge    string
+		srcField field
+		want     string
+	}{
+		{name: "Value", merge: mergeLastWins, srcField: field{Name: "F", TypeStr: "int", Underlying: "int"}, want: ""},
+		{name: "Pointer", merge: mergeFirstNonNil, srcField: field{Name: "F", Ptr: true, TypeStr: "*int", Underlying: "*int"}, want: "src.F != nil"},
+		{name: "Non zero number", merge: mergeFirstNonZero, srcField: field{Name: "F", TypeStr: "model.UserID", Underlying: "int64"}, want: "src.F != 0"},
+		{name: "Non zero string", merge: mergeFirstNonZero, srcField: field{Name: "F", TypeStr: "string", Underlying: "string"}, want: "src.F != \"\""},
+		{name: "Non zero slice", merge: mergeFirstNonZero, srcField: field{Name: "F", Ptr: true, TypeStr: "[]int", Underlying: "[]int"}, want: "len(src.F) != 0"},
+		{name: "Non zero structure", merge: mergeFirstNonZero, srcField: field{Name: "F", TypeStr: "time.Time", Underlying: "time.Time"}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fieldGuard(tt.merge, "src", tt.srcField); got != tt.want {
+				t.Errorf("fieldGuard() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_joinKeys(t *testing.T) {
+	user := src{Alias: "user", ShortPath: "model.User", Fields: []field{{Name: "ID", TypeStr: "int64", Underlying: "int64"}}}
+	profile := src{Alias: "profile", ShortPath: "model.Profile", Fields: []field{{Name: "UserID", TypeStr: "int64", Underlying: "int64"}}}
+	named := src{Alias: "named", ShortPath: "model.Named", Fields: []field{{Name: "ID", TypeStr: "model.UserID", Underlying: "int64"}}}
+	tests := []struct {
+		name         string
+		mapperConfig mapperConfig
+		srcList      []src
+		want         listSource
+		wantErr      bool
+	}{
+		{
+			name:         "Join key field",
+			mapperConfig: mapperConfig{JoinKey: "ID"},
+			srcList:      []src{user, {Alias: "account", Fields: []field{{Name: "ID", TypeStr: "int64", Underlying: "int64"}}}},
+			want:         listSource{Key: "v.ID", KeyType: "int64", PrimaryKey: "user[i].ID"},
+		},
+		{
+			name:         "Join key field of another type",
+			mapperConfig: mapperConfig{JoinKey: "ID"},
+			srcList:      []src{user, named},
+			wantErr:      true,
+		},
+		{
+			name:         "Join key field missing",
+			mapperConfig: mapperConfig{JoinKey: "UserID"},
+			srcList:      []src{user, profile},
+			wantErr:      true,
+		},
+		{
+			name:         "Join key expressions",
+			mapperConfig: mapperConfig{Sources: []sourceConfig{{Alias: "user", JoinKey: "user.ID"}, {Alias: "profile", JoinKey: "profile.UserID"}}},
+			srcList:      []src{user, profile},
+			want:         listSource{Key: "v.UserID", KeyType: "int64", PrimaryKey: "user[i].ID"},
+		},
+		{
+			name:         "Join key expression over the join key field",
+			mapperConfig: mapperConfig{JoinKey: "ID", Sources: []sourceConfig{{Alias: "user"}, {Alias: "profile", JoinKey: "profile.UserID"}}},
+			srcList:      []src{user, profile},
+			want:         listSource{Key: "v.UserID", KeyType: "int64", PrimaryKey: "user[i].ID"},
+		},
+		{
+			name:         "Join key type",
+			mapperConfig: mapperConfig{JoinKeyType: "string", Sources: []sourceConfig{{Alias: "user", JoinKey: "strconv.FormatInt(user.ID, 10)"}, {Alias: "profile", JoinKey: "fmt.Sprint(profile.UserID)"}}},
+			srcList:      []src{user, profile},
+			want:         listSource{Key: "fmt.Sprint(v.UserID)", KeyType: "string", PrimaryKey: "strconv.FormatInt(user[i].ID, 10)"},
+		},
+		{
+			name:         "Unknown join key type",
+			mapperConfig: mapperConfig{Sources: []sourceConfig{{Alias: "user", JoinKey: "user.ID"}, {Alias: "profile", JoinKey: "fmt.Sprint(profile.UserID)"}}},
+			srcList:      []src{user, profile},
+			wantErr:      true,
+		},
+		{
+			name:         "Join key of another source",
+			mapperConfig: mapperConfig{Sources: []sourceConfig{{Alias: "user", JoinKey: "user.ID"}, {Alias: "profile", JoinKey: "user.ID"}}},
+			srcList:      []src{user, profile},
+			wantErr:      true,
+		},
+		{
+			name:         "No join key",
+			mapperConfig: mapperConfig{},
+			srcList:      []src{user, profile},
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listSources := make([]listSource, len(tt.srcList))
+			err := joinKeys(tt.mapperConfig, tt.srcList, listSources)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("joinKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(listSources[1], tt.want) {
+				t.Errorf("joinKeys() = %v, want %v", listSources[1], tt.want)
+			}
+		})
+	}
+}
+
+func Test_listParams(t *testing.T) {
+	user := src{Alias: "user", ShortPath: "model.User", Fields: []field{{Name: "ID", TypeStr: "int64", Underlying: "int64"}}}
+	profile := src{Alias: "profile", ShortPath: "model.Profile", Fields: []field{{Name: "ID", TypeStr: "int64", Underlying: "int64"}}}
+	tests := []struct {
+		name         string
+		mapperConfig mapperConfig
+		options      castOptions
+		want         []listSource
+	}{
+		{
+			name:         "Pointers",
+			mapperConfig: mapperConfig{},
+			want:         []listSource{{Alias: "user", ShortPath: "model.User", Item: "user[i]"}, {Alias: "profile", ShortPath: "model.Profile", Item: "profile[i]"}},
+		},
+		{
+			name:         "Value list of pointer mapper",
+			mapperConfig: mapperConfig{ListInput: signatureValue, List: listZipLongest},
+			want:         []listSource{{Alias: "user", ShortPath: "model.User", Item: "&userItem"}, {Alias: "profile", ShortPath: "model.Profile", Item: "&profileItem"}},
+		},
+		{
+			name:         "Pointer list of value mapper",
+			mapperConfig: mapperConfig{Input: signatureValue, ListInput: signaturePointer},
+			want:         []listSource{{Alias: "user", ShortPath: "model.User", Item: "mapping.Deref(user[i])"}, {Alias: "profile", ShortPath: "model.Profile", Item: "mapping.Deref(profile[i])"}},
+		},
+		{
+			name:         "Pointer list of value mapper inline",
+			mapperConfig: mapperConfig{Input: signatureValue, ListInput: signaturePointer},
+			options:      castOptions{inlineHelpers: true},
+			want: []listSource{
+				{Alias: "user", ShortPath: "model.User", Item: "func(p *model.User) (v model.User) { if p != nil { v = *p }; return v }(user[i])"},
+				{Alias: "profile", ShortPath: "model.Profile", Item: "func(p *model.Profile) (v model.Profile) { if p != nil { v = *p }; return v }(profile[i])"},
+			},
+		},
+		{
+			name:         "Joined value list of pointer mapper",
+			mapperConfig: mapperConfig{ListInput: signatureValue, List: listJoin, JoinKey: "ID"},
+			want: []listSource{
+				{Alias: "user", ShortPath: "model.User", Item: "&user[i]", Primary: true},
+				{Alias: "profile", ShortPath: "model.Profile", Item: "profileItem", Key: "v.ID", KeyType: "int64", PrimaryKey: "user[i].ID", IndexItem: "&profile[j]", IndexPos: true},
+			},
+		},
+		{
+			name:         "Joined pointer list of value mapper",
+			mapperConfig: mapperConfig{Input: signatureValue, ListInput: signaturePointer, List: listJoin, JoinKey: "ID"},
+			want: []listSource{
+				{Alias: "user", ShortPath: "model.User", Item: "mapping.Deref(user[i])", Primary: true},
+				{Alias: "profile", ShortPath: "model.Profile", Item: "profileItem", Key: "v.ID", KeyType: "int64", PrimaryKey: "user[i].ID", IndexItem: "*v"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got, err := listParams(tt.mapperConfig, []src{user, profile}, tt.options)
+			if err != nil {
+				t.Fatalf("listParams() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("listParams() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mapOutputParams(t *testing.T) {
+	user := src{Alias: "user", ShortPath: "model.User", Fields: []field{{Name: "ID", TypeStr: "int64", Underlying: "int64"}}}
+	tests := []struct {
+		name         string
+		mapperConfig mapperConfig
+		srcList      []src
+		want         *indexParams
+		wantErr      bool
+	}{
+		{name: "No map outputs", mapperConfig: mapperConfig{}, srcList: []src{user, user}, want: nil},
+		{name: "Map mapper", mapperConfig: mapperConfig{MapMapper: true}, srcList: []src{user}, want: nil},
+		{name: "Several sources", mapperConfig: mapperConfig{MapMapper: true}, srcList: []src{user, user}, wantErr: true},
+		{name: "Key field", mapperConfig: mapperConfig{Index: &indexConfig{Key: "ID"}}, srcList: []src{user}, want: &indexParams{Key: "v.ID", KeyType: "int64", Duplicates: duplicatesLast}},
+		{name: "Key expression", mapperConfig: mapperConfig{Index: &indexConfig{Key: "fmt.Sprint(user.ID)", KeyType: "string", Duplicates: duplicatesError}}, srcList: []src{user}, want: &indexParams{Key: "fmt.Sprint(v.ID)", KeyType: "string", Duplicates: duplicatesError}},
+		{name: "Unknown key type", mapperConfig: mapperConfig{Index: &indexConfig{Key: "fmt.Sprint(user.ID)"}}, srcList: []src{user}, wantErr: true},
+		{name: "Unknown duplicates", mapperConfig: mapperConfig{Index: &indexConfig{Key: "ID", Duplicates: "any"}}, srcList: []src{user}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mapOutputParams(tt.mapperConfig, tt.srcList)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mapOutputParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mapOutputParams() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseDirectives(t *testing.T) {
+	doc := &ast.CommentGroup{List: []*ast.Comment{
+		{Text: "// ToModel maps the user."},
+		{Text: "//mapstruct:checked"},
+		{Text: "//mapstruct:merge=first_non_nil"},
+		{Text: "//mapstruct:relation Name: src.First + \" \" + src.Last"},
+	}}
+	want := []directive{
+		{Key: "checked"},
+		{Key: "merge", Value: "first_non_nil"},
+		{Key: "relation", Value: "Name: src.First + \" \" + src.Last"},
+	}
+	if got := parseDirectives(doc); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDirectives() = %v, want %v", got, want)
+	}
+}
+
+func Test_applyMapperDirective(t *testing.T) {
+	tests := []struct {
+		name      string
+		directive directive
+		want      mapperConfig
+		wantErr   bool
+	}{
+		{name: "Errors", directive: directive{Key: "errors"}, want: mapperConfig{Errors: true}},
+		{name: "Relation", directive: directive{Key: "relation", Value: "Name: src.Title"}, want: mapperConfig{Relations: []string{"Name: src.Title"}}},
+		{name: "Relation without a field", directive: directive{Key: "relation", Value: "src.Title"}, wantErr: true},
+		{name: "Relation with colons", directive: directive{Key: "relation", Value: `Tags: map[string]int{"a": 1}`}, wantErr: true},
+		{name: "Unknown", directive: directive{Key: "skip"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got mapperConfig
+			err := applyMapperDirective(&got, tt.directive)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyMapperDirective() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyMapperDirective() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_structMappers(t *testing.T) {
+	resolver := newTypePathResolver(t)
+	tests := []struct {
+		name    string
+		src     string
+		maps    []string
+		want    []mapperConfig
+		wantErr bool
+	}{
+		{
+			name: "Source",
+			src:  "struct {\n//mapstruct:to=Title\nName string\nAge int }",
+			maps: []string{"Dst=model.View"},
+			want: []mapperConfig{{
+				Sources:     []sourceConfig{{Alias: "src", Path: "api/page.User"}},
+				Destination: sourceConfig{Alias: "dst", Path: "model/user.View"},
+				Relations:   []string{"Title: src.Name"},
+			}},
+		},
+		{
+			name: "Destination",
+			src:  "struct {\n//mapstruct:from=src.Title\nName string\n//mapstruct:ignore\nAge int }",
+			maps: []string{"Src=model.View"},
+			want: []mapperConfig{{
+				Sources:     []sourceConfig{{Alias: "src", Path: "model/user.View"}},
+				Destination: sourceConfig{Alias: "dst", Path: "api/page.User"},
+				Ignore:      []string{"Age"},
+				Relations:   []string{"Name: src.Title"},
+			}},
+		},
+		{
+			name: "Both ways",
+			src:  "struct {\n//mapstruct:from=src.Title\n//mapstruct:to=Title\nName string }",
+			maps: []string{"Dst=model.View", "Src=model.View"},
+			want: []mapperConfig{{
+				Sources:     []sourceConfig{{Alias: "src", Path: "api/page.User"}},
+				Destination: sourceConfig{Alias: "dst", Path: "model/user.View"},
+				Relations:   []string{"Title: src.Name"},
+			}, {
+				Sources:     []sourceConfig{{Alias: "src", Path: "model/user.View"}},
+				Destination: sourceConfig{Alias: "dst", Path: "api/page.User"},
+				Relations:   []string{"Name: src.Title"},
+			}},
+		},
+		{name: "From on a source", src: "struct {\n//mapstruct:from=src.Title\nName string }", maps: []string{"Dst=model.View"}, wantErr: true},
+		{name: "Ignore on a source", src: "struct {\n//mapstruct:ignore\nName string }", maps: []string{"Dst=model.View"}, wantErr: true},
+		{name: "To on a destination", src: "struct {\n//mapstruct:to=Title\nName string }", maps: []string{"Src=model.View"}, wantErr: true},
+		{name: "Unknown directive", src: "struct {\n//mapstruct:skip\nName string }", maps: []string{"Src=model.View"}, wantErr: true},
+		{name: "From with a colon", src: "struct {\n//mapstruct:from=map[string]int{\"a\": 1}\nName string }", maps: []string{"Src=model.View"}, wantErr: true},
+		{name: "Empty from", src: "struct {\n//mapstruct:from=\nName string }", maps: []string{"Src=model.View"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileAST, err := parser.ParseFile(token.NewFileSet(), "user.go", "package api\n\ntype User "+tt.src, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			structType := fileAST.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec).Type.(*ast.StructType)
+			var directives []directive
+			for _, value := range tt.maps {
+				directives = append(directives, directive{Key: "map", Value: value})
+			}
+			got, err := structMappers("api/page.User", structType, directives, resolver)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("structMappers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("structMappers() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_goModulePath(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "Module", data: "module example.com/app\n\ngo 1.21\n", want: "example.com/app"},
+		{name: "Quoted module", data: "// app\nmodule \"example.com/app\"\n", want: "example.com/app"},
+		{name: "No module", data: "go 1.21\n", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := goModulePath([]byte(tt.data)); got != tt.want {
+				t.Errorf("goModulePath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mergeMappers(t *testing.T) {
+	configured := mapperConfig{Alias: "Member", Merge: mergeFirstNonNil, Relations: []string{"Nick: src.Alias"}}
+	declared := mapperConfig{Alias: "Member", Merge: mergeLastWins, Checked: true, Errors: true, Relations: []string{"Alias: src.Nick"}, Ignore: []string{"Email"}}
+	other := mapperConfig{Alias: "Other"}
+	want := []mapperConfig{
+		{Alias: "Member", Merge: mergeFirstNonNil, Checked: true, Errors: true, Relations: []string{"Alias: src.Nick", "Nick: src.Alias"}, Ignore: []string{"Email"}},
+		other,
+	}
+	got, err := mergeMappers([]mapperConfig{configured}, []mapperConfig{declared, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeMappers() = %v, want %v", got, want)
+	}
+	if _, err := mergeMappers(nil, []mapperConfig{other, {Alias: "Other", Checked: true}}); err == nil {
+		t.Error("mergeMappers() of mappers declared twice error = nil")
+	}
+}
+
+func Test_cloneStr(t *testing.T) {
+	options := castOptions{
+		mappers: map[[2]string]nestedMapper{
+			{"model.Meta", "model.Meta"}: {MapperFuncName: "cloneMeta", ListMapperFuncName: "cloneMetaList"},
+		},
+	}
+	tests := []struct {
+		name    string
+		typeStr string
+		want    string
+		typed   bool
+	}{
+		{name: "Value", typeStr: "int64", want: ""},
+		{name: "Pointer", typeStr: "*string", want: "mapping.ClonePtr(src.F)"},
+		{name: "Slice", typeStr: "[]string", want: "mapping.CloneSlice(src.F)", typed: true},
+		{name: "Map", typeStr: "map[string]int", want: "mapping.CloneMap(src.F)", typed: true},
+		{name: "Slice of slices", typeStr: "[][]int", want: "mapping.MapSlice(src.F, func(v []int) []int { return mapping.CloneSlice(v) })"},
+		{name: "Structure pointer", typeStr: "*model.Meta", want: "cloneMeta(src.F)", typed: true},
+		{name: "Structure pointers", typeStr: "[]*model.Meta", want: "mapping.MapSlice(src.F, cloneMeta)"},
+		{name: "Structure map", typeStr: "map[string]model.Meta", want: "mapping.MapMap(src.F, func(v model.Meta) model.Meta { return mapping.Deref(cloneMeta(&v)) })"},
+		{name: "Pointer to pointer", typeStr: "**int", want: "func(v **int) **int { if v == nil { return nil }; c := mapping.ClonePtr(*v); return &c }(src.F)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, typed := cloneStr("src.F", tt.typeStr, options)
+			if got != tt.want {
+				t.Errorf("cloneStr() = %v, want %v", got, tt.want)
+			}
+			if typed != tt.typed {
+				t.Errorf("cloneStr() typed = %v, want %v", typed, tt.typed)
+			}
+		})
+	}
+}
+
+func Test_castCloneField(t *testing.T) {
+	tests := []struct {
+		name     string
+		srcField field
+		want     string
+	}{
+		{name: "Value", srcField: field{Name: "F", TypeStr: "model.Status", Underlying: "int"}, want: "src.F"},
+		{name: "Named slice", srcField: field{Name: "F", TypeStr: "model.Tags", Underlying: "[]string"}, want: "mapping.CloneSlice(src.F)"},
+		{name: "Named map", srcField: field{Name: "F", TypeStr: "model.Labels", Underlying: "map[string]string"}, want: "mapping.CloneMap(src.F)"},
+		{name: "Named pointer", srcField: field{Name: "F", TypeStr: "model.Ref", Underlying: "*int"}, want: "model.Ref(mapping.ClonePtr(src.F))"},
+		{name: "Named slice of slices", srcField: field{Name: "F", TypeStr: "model.Matrix", Underlying: "[][]int"},
+			want: "model.Matrix(mapping.MapSlice(src.F, func(v []int) []int { return mapping.CloneSlice(v) }))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := castCloneField("src", tt.srcField, castOptions{}); got != tt.want {
+				t.Errorf("castCloneField() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_graphMapperParams(t *testing.T) {
+	category := sourceConfig{Alias: "src", Path: "model/tree.Category"}
+	tests := []struct {
+		name         string
+		mapperConfig mapperConfig
+		want         *graphParams
+		wantErr      bool
+	}{
+		{name: "No graph", mapperConfig: mapperConfig{Sources: []sourceConfig{category, category}}, want: nil},
+		{name: "Cycles", mapperConfig: mapperConfig{Cycles: true, Sources: []sourceConfig{category}}, want: &graphParams{FuncName: "categoryMapperGraph", Type: "mapping.Graph", New: "mapping.NewGraph(0)", Cycles: true}},
+		{name: "Max depth", mapperConfig: mapperConfig{Alias: "Tree", MaxDepth: 3, Sources: []sourceConfig{category}}, want: &graphParams{FuncName: "treeMapperGraph", Type: "mapping.Graph", New: "mapping.NewGraph(3)"}},
+		{name: "Negative max depth", mapperConfig: mapperConfig{MaxDepth: -1, Sources: []sourceConfig{category}}, wantErr: true},
+		{name: "Several sources", mapperConfig: mapperConfig{Cycles: true, Sources: []sourceConfig{category, category}}, wantErr: true},
+		{name: "Value output", mapperConfig: mapperConfig{Cycles: true, Output: signatureValue, Sources: []sourceConfig{category}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mapperConfig.Destination = sourceConfig{Alias: "dst", Path: "model/tree.Category"}
+			got, err := graphMapperParams(tt.mapperConfig, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("graphMapperParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("graphMapperParams() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_castFuncField(t *testing.T) {
+	funcs := []funcConfig{
+		{Src: "time.Time", Dst: "string", Func: "conv.FormatTime", Context: true},
+		{Src: "string", Dst: "int64", Func: "strconv.ParseInt10", Errors: true},
+		{Src: "int64", Dst: "string", Func: "conv.FormatID"},
+	}
+	tests := []struct {
+		name        string
+		srcField    field
+		dstField    field
+		options     castOptions
+		wantCastStr string
+		wantChecked bool
+		wantOk      bool
+	}{
+		{name: "Plain", srcField: field{Name: "ID", TypeStr: "int64"}, dstField: field{Name: "ID", TypeStr: "string"}, options: castOptions{funcs: funcs}, wantCastStr: "conv.FormatID(src.ID)", wantOk: true},
+		{name: "Context", srcField: field{Name: "At", TypeStr: "time.Time"}, dstField: field{Name: "At", TypeStr: "string"}, options: castOptions{funcs: funcs, context: true}, wantCastStr: "conv.FormatTime(ctx, src.At)", wantOk: true},
+		{name: "Context not passed", srcField: field{Name: "At", TypeStr: "time.Time"}, dstField: field{Name: "At", TypeStr: "string"}, options: castOptions{funcs: funcs}},
+		{name: "Error", srcField: field{Name: "ID", TypeStr: "string"}, dstField: field{Name: "ID", TypeStr: "int64"}, options: castOptions{funcs: funcs, returnsError: true}, wantCastStr: "strconv.ParseInt10(src.ID)", wantChecked: true, wantOk: true},
+		{name: "Error not returned", srcField: field{Name: "ID", TypeStr: "string"}, dstField: field{Name: "ID", TypeStr: "int64"}, options: castOptions{funcs: funcs}},
+		{name: "Other types", srcField: field{Name: "ID", TypeStr: "int32"}, dstField: field{Name: "ID", TypeStr: "string"}, options: castOptions{funcs: funcs}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			castStr, checked, ok := castFuncField("src", tt.srcField, tt.dstField, tt.options)
+			if castStr != tt.wantCastStr || checked != tt.wantChecked || ok != tt.wantOk {
+				t.Errorf("castFuncField() = %v, %v, %v, want %v, %v, %v", castStr, checked, ok, tt.wantCastStr, tt.wantChecked, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_castParamField(t *testing.T) {
+	params := []paramConfig{{Name: "tenantID", Type: "string"}, {Name: "now", Type: "time.Time"}}
+	tests := []struct {
+		name        string
+		dstField    field
+		wantCastStr string
+		wantOk      bool
+	}{
+		{name: "Name case aside", dstField: field{Name: "TenantID", TypeStr: "string"}, wantCastStr: "tenantID", wantOk: true},
+		{name: "Pointer", dstField: field{Name: "Now", TypeStr: "*time.Time"}, wantCastStr: "&now", wantOk: true},
+		{name: "Other type", dstField: field{Name: "TenantID", TypeStr: "int64"}},
+		{name: "Other name", dstField: field{Name: "Tenant", TypeStr: "string"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			castStr, ok := castParamField(params, tt.dstField)
+			if castStr != tt.wantCastStr || ok != tt.wantOk {
+				t.Errorf("castParamField() = %v, %v, want %v, %v", castStr, ok, tt.wantCastStr, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_valueExpr(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		dstField field
+		want     string
+		wantErr  bool
+	}{
+		{name: "Integer", value: 2, dstField: field{TypeStr: "int32", Underlying: "int32"}, want: "2"},
+		{name: "Negative unsigned", value: -2, dstField: field{TypeStr: "uint", Underlying: "uint"}, wantErr: true},
+		{name: "Float into integer", value: 1.5, dstField: field{TypeStr: "int64", Underlying: "int64"}, wantErr: true},
+		{name: "Bool", value: true, dstField: field{TypeStr: "bool", Underlying: "bool"}, want: "true"},
+		{name: "Bare word", value: "api", dstField: field{TypeStr: "string", Underlying: "string"}, want: `"api"`},
+		{name: "Words", value: "n/a", dstField: field{TypeStr: "string", Underlying: "string"}, want: `"n/a"`},
+		{name: "Bare word into integer", value: "api", dstField: field{TypeStr: "int", Underlying: "int"}, wantErr: true},
+		{name: "Host", value: "api.example.com", dstField: field{TypeStr: "string", Underlying: "string"}, want: `"api.example.com"`},
+		{name: "Version", value: "v1.2", dstField: field{TypeStr: "model.Version", Underlying: "string"}, want: `"v1.2"`},
+		{name: "Quoted", value: `"api"`, dstField: field{TypeStr: "string", Underlying: "string"}, want: `"\"api\""`},
+		{name: "Literal", value: map[interface{}]interface{}{"expr": `"api"`}, dstField: field{TypeStr: "model.Source", Underlying: "string"}, want: `"api"`},
+		{name: "Composite literal", value: map[interface{}]interface{}{"expr": `[]string{"a"}`}, dstField: field{TypeStr: "[]string", Underlying: "[]string"}, want: `[]string{"a"}`},
+		{name: "Package not imported", value: map[interface{}]interface{}{"expr": "status.Active"}, dstField: field{TypeStr: "string", Underlying: "string"}, wantErr: true},
+		{name: "Unqualified name", value: map[interface{}]interface{}{"expr": "api"}, dstField: field{TypeStr: "string", Underlying: "string"}, wantErr: true},
+		{name: "Invalid expression", value: map[interface{}]interface{}{"expr": "n/"}, dstField: field{TypeStr: "string", Underlying: "string"}, wantErr: true},
+		{name: "Other key", value: map[interface{}]interface{}{"value": "api"}, dstField: field{TypeStr: "string", Underlying: "string"}, wantErr: true},
+		{name: "Pointer", value: 2, dstField: field{TypeStr: "*int32", Underlying: "*int32"}, want: "mapping.Ptr(int32(2))"},
+		{name: "Pointer of untyped default", value: "api", dstField: field{TypeStr: "*string", Underlying: "*string"}, want: `mapping.Ptr("api")`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := valueExpr(".", tt.value, tt.dstField, nil, castOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("valueExpr() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("valueExpr() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_searchUsedSrc(t *testing.T) {
+	srcList := []src{{Alias: "u"}, {Alias: "user"}}
+	tests := []struct {
+		name       string
+		srcCastRow string
+		want       string
+	}{
+		{name: "Alias", srcCastRow: "user.Name", want: "user"},
+		{name: "Alias substring", srcCastRow: "strings.ToUpper(user.Name)", want: "user"},
+		{name: "Selected name", srcCastRow: "pkg.u", want: ""},
+		{name: "Literal", srcCastRow: `"u"`, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			if usedSrc := searchUsedSrc(srcList, tt.srcCastRow); usedSrc != nil {
+				got = usedSrc.Alias
+			}
+			if got != tt.want {
+				t.Errorf("searchUsedSrc() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_relationGuard(t *testing.T) {
+	srcList := []src{{Alias: "user"}, {Alias: "profile"}, {Alias: "account"}}
+	tests := []struct {
+		name         string
+		mapperConfig mapperConfig
+		srcCastRow   string
+		want         string
+	}{
+		{name: "Single source", srcCastRow: "strings.ToUpper(user.Name)", want: ""},
+		{name: "Other sources", srcCastRow: "user.Name + profile.Bio + account.Login", want: "profile != nil && account != nil"},
+		{name: "Values", mapperConfig: mapperConfig{Input: signatureValue}, srcCastRow: "user.Name + profile.Bio", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := relationGuard(tt.mapperConfig, srcList, "user", tt.srcCastRow); got != tt.want {
+				t.Errorf("relationGuard() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_conditionGuard(t *testing.T) {
+	user := src{Alias: "user", ShortPath: "model.User", Fields: []field{{Name: "Verified", TypeStr: "bool", Underlying: "bool"}, {Name: "Name", TypeStr: "string", Underlying: "string"}}, Methods: map[string]bool{"IsAdmin": true}}
+	profile := src{Alias: "profile", ShortPath: "model.Profile", Fields: []field{{Name: "Public", TypeStr: "bool", Underlying: "bool"}}}
+	mapperConfig := mapperConfig{Params: []paramConfig{{Name: "internal", Type: "bool"}}}
+	tests := []struct {
+		name      string
+		srcAlias  string
+		condition string
+		want      string
+		wantErr   bool
+	}{
+		{name: "Field", srcAlias: "user", condition: "user.Verified", want: "user.Verified"},
+		{name: "Method", srcAlias: "user", condition: "user.IsAdmin() || internal", want: "(user.IsAdmin() || internal)"},
+		{name: "Other source", srcAlias: "user", condition: "profile.Public", want: "profile != nil && profile.Public"},
+		{name: "Unsourced", condition: "len(user.Name) != 0", want: "user != nil && len(user.Name) != 0"},
+		{name: "Not a bool", srcAlias: "user", condition: "!user.Name", wantErr: true},
+		{name: "Unknown field", srcAlias: "user", condition: "user.Email != \"\"", wantErr: true},
+		{name: "Unknown name", srcAlias: "user", condition: "admin", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := conditionGuard(mapperConfig, []src{user, profile}, nil, tt.srcAlias, tt.condition)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("conditionGuard() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("conditionGuard() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mapperHooks(t *testing.T) {
+	srcList := []src{{Alias: "user"}, {Alias: "profile"}}
+	tests := []struct {
+		name         string
+		mapperConfig mapperConfig
+		wantBefore   *hookParams
+		wantAfter    *hookParams
+	}{
+		{name: "No hooks", mapperConfig: mapperConfig{}},
+		{
+			name:         "Pointers",
+			mapperConfig: mapperConfig{Alias: "View", Before: "hooks.Normalize", After: "hooks.Derive", Destination: sourceConfig{Alias: "dst"}},
+			wantBefore:   &hookParams{Call: "hooks.Normalize(user, profile)", Guard: "user != nil || profile != nil", Zero: "nil", Label: "ViewMapper: before"},
+			wantAfter:    &hookParams{Call: "hooks.Derive(user, profile, dst)", Guard: "dst != nil", Zero: "nil", Label: "ViewMapper: after"},
+		},
+		{
+			name:         "Values returning errors",
+			mapperConfig: mapperConfig{Alias: "View", Context: true, Errors: true, Input: signatureValue, Output: signatureValue, After: "hooks.Validate", Destination: sourceConfig{Alias: "dst"}},
+			wantAfter:    &hookParams{Call: "hooks.Validate(ctx, &user, &profile, &dst)", ReturnsError: true, Zero: "nil", Label: "ViewMapper: after"},
+		},
+		{
+			name: "Extra parameters",
+			mapperConfig: mapperConfig{Alias: "View", Context: true, Before: "hooks.Normalize", After: "hooks.Derive", Destination: sourceConfig{Alias: "dst"},
+				Params: []paramConfig{{Name: "tenantID", Type: "string"}, {Name: "locale", Type: "string"}}},
+			wantBefore: &hookParams{Call: "hooks.Normalize(ctx, user, profile, tenantID, locale)", Guard: "user != nil || profile != nil", Zero: "nil", Label: "ViewMapper: before"},
+			wantAfter:  &hookParams{Call: "hooks.Derive(ctx, user, profile, dst, tenantID, locale)", Guard: "dst != nil", Zero: "nil", Label: "ViewMapper: after"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after := mapperHooks(tt.mapperConfig, srcList, "nil")
+			if !reflect.DeepEqual(before, tt.wantBefore) {
+				t.Errorf("mapperHooks() before = %v, want %v", before, tt.wantBefore)
+			}
+			if !reflect.DeepEqual(after, tt.wantAfter) {
+				t.Errorf("mapperHooks() after = %v, want %v", after, tt.wantAfter)
+			}
+		})
+	}
+}
+
+func Test_validationHooks(t *testing.T) {
+	srcList := []src{{Alias: "user"}}
+	tests := []struct {
+		name         string
+		mapperConfig mapperConfig
+		want         []*hookParams
+	}{
+		{name: "No validation", mapperConfig: mapperConfig{}},
+		{
+			name:         "Method and validator",
+			mapperConfig: mapperConfig{Alias: "View", Validate: true, Validator: "rules.View", Destination: sourceConfig{Alias: "dst"}},
+			want: []*hookParams{
+				{Call: "mapping.Validate(dst)", Guard: "dst != nil", ReturnsError: true, Zero: "nil", Label: "ViewMapper: validate"},
+				{Call: "rules.View(dst)", Guard: "dst != nil", ReturnsError: true, Zero: "nil", Label: "ViewMapper: validate"},
+			},
+		},
+		{
+			name:         "Values",
+			mapperConfig: mapperConfig{Alias: "View", Validator: "rules.View", Input: signatureValue, Output: signatureValue, Destination: sourceConfig{Alias: "dst"}},
+			want:         []*hookParams{{Call: "rules.View(&dst)", ReturnsError: true, Zero: "nil", Label: "ViewMapper: validate"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validationHooks(tt.mapperConfig, srcList, "nil", false); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validationHooks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mapperConfig_funcName(t *testing.T) {
+	user := sourceConfig{Alias: "src", Path: "model/user.User"}
+	dynamicMap := sourceConfig{Alias: "src", Path: dynamicMapPath}
+	tests := []struct {
+		name         string
+		mapperConfig mapperConfig
+		want         string
+	}{
+		{name: "Alias", mapperConfig: mapperConfig{Alias: "View", Destination: dynamicMap, Sources: []sourceConfig{user}}, want: "ViewMapper"},
+		{name: "Destination", mapperConfig: mapperConfig{Destination: sourceConfig{Path: "pb/user.User"}, Sources: []sourceConfig{user}}, want: "UserMapper"},
+		{name: "Into a map", mapperConfig: mapperConfig{Destination: dynamicMap, Sources: []sourceConfig{user}}, want: "UserToMapMapper"},
+		{name: "From a map", mapperConfig: mapperConfig{Destination: user, Sources: []sourceConfig{dynamicMap}}, want: "UserFromMapMapper"},
+		{name: "Deep copy", mapperConfig: mapperConfig{DeepCopy: true, Destination: user, Sources: []sourceConfig{user}}, want: "CloneUser"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.mapperConfig.MapperName(); got != tt.want {
+				t.Errorf("MapperName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_castMapField(t *testing.T) {
+	mappers := map[[2]string]nestedMapper{
+		{"model.Address", dynamicMapType}: {MapperFuncName: "addressToMapMapper", ListMapperFuncName: "addressToMapListMapper", OutputValue: true},
+		{dynamicMapType, "model.Address"}: {MapperFuncName: "addressFromMapMapper", ReturnsError: true, InputValue: true},
+	}
+	toMap := mapperConfig{MapKey: "json", Destination: sourceConfig{Path: dynamicMapPath}}
+	fromMap := mapperConfig{MapKey: "json", Sources: []sourceConfig{{Path: dynamicMapPath}}}
+	tests := []struct {
+		name         string
+		mapperConfig mapperConfig
+		srcField     field
+		dstField     field
+		want         fieldMappingRule
+	}{
+		{
+			name:         "Omit empty",
+			mapperConfig: toMap,
+			srcField:     field{Name: "Tags", Ptr: true, TypeStr: "[]string", Underlying: "[]string", Tag: "`json:\"tags,omitempty\"`"},
+			want:         fieldMappingRule{CastStr: "src.Tags", Casted: true, Guard: "len(src.Tags) != 0"},
+		},
+		{
+			name:         "Time",
+			mapperConfig: mapperConfig{TimeFormat: "2006-01-02", Destination: sourceConfig{Path: dynamicMapPath}},
+			srcField:     field{Name: "At", Ptr: true, TypeStr: "*time.Time", Underlying: "*time.Time"},
+			want:         fieldMappingRule{CastStr: `src.At.Format("2006-01-02")`, Casted: true, Guard: "src.At != nil"},
+		},
+		{
+			name:         "Nested map",
+			mapperConfig: toMap,
+			srcField:     field{Name: "Home", TypeStr: "model.Address", Underlying: "model.Address"},
+			want:         fieldMappingRule{CastStr: "addressToMapMapper(&src.Home)", Casted: true},
+		},
+		{
+			name:         "Map value",
+			mapperConfig: fromMap,
+			srcField:     field{Name: "ID", Key: "id", TypeStr: "any"},
+			dstField:     field{Name: "ID", TypeStr: "int64"},
+			want:         fieldMappingRule{CastStr: `mapping.FromMap[int64](src, "id")`, Casted: true, Checked: true, Guard: `src["id"] != nil`},
+		},
+		{
+			name:         "Nested structure",
+			mapperConfig: fromMap,
+			srcField:     field{Name: "Home", Key: "home", TypeStr: "any"},
+			dstField:     field{Name: "Home", TypeStr: "model.Address"},
+			want:         fieldMappingRule{CastStr: `mapping.NestedFromMap(src, "home", addressFromMapMapper)`, Casted: true, Checked: true, CastDeref: true, Guard: `src["home"] != nil`},
+		},
+		{
+			name:         "Nested structure pointers",
+			mapperConfig: fromMap,
+			srcField:     field{Name: "Refs", Key: "refs", TypeStr: "any"},
+			dstField:     field{Name: "Refs", TypeStr: "[]*model.Address"},
+			want:         fieldMappingRule{CastStr: `mapping.NestedSliceFromMap(src, "refs", addressFromMapMapper)`, Casted: true, Checked: true, Guard: `src["refs"] != nil`},
+		},
+		{
+			name:         "Nested structure values",
+			mapperConfig: fromMap,
+			srcField:     field{Name: "Addrs", Key: "addrs", TypeStr: "any"},
+			dstField:     field{Name: "Addrs", TypeStr: "[]model.Address"},
+			want: fieldMappingRule{
+				CastStr: `mapping.NestedSliceFromMap(src, "addrs", func(m map[string]any) (model.Address, error) { v, err := addressFromMapMapper(m); return mapping.Deref(v), err })`,
+				Casted:  true,
+				Checked: true,
+				Guard:   `src["addrs"] != nil`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := castMapField(fieldMappingRule{}, tt.mapperConfig, "src", tt.srcField, tt.dstField, castOptions{mappers: mappers})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("castMapField() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_typeStrValue(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{name: "Empty interface", expr: "interface{}", want: "interface{}"},
+		{name: "Interface", expr: "interface{ Color() string }", want: "interface{ Color() string }"},
+		{name: "Interface slice", expr: "[]interface{}", want: "[]interface{}"},
+		{name: "Map of any", expr: "map[string]any", want: "map[string]any"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := typeStrValue(expr); got != tt.want {
+				t.Errorf("typeStrValue() = %v, want %v", got, tt.want)
+			}
+			if !isPtr(expr) {
+				t.Errorf("isPtr() = false for %s", tt.expr)
+			}
+		})
+	}
+}
+
+func Test_variantCases(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "model"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	shapes := "package model\n\ntype Circle struct{ R int }\n\ntype CircleDTO struct{ R int }\n\ntype Square struct{ Side int }\n\ntype Wrap struct{ Circle *Circle }\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "model", "shape.go"), []byte(shapes), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dstField := field{Name: "Shape", TypeStr: "any"}
+	circle := variantConfig{Source: "model/shape.Circle", Destination: "model/shape.CircleDTO"}
+	mappers := map[[2]string]nestedMapper{{"model.Circle", "model.CircleDTO"}: {MapperFuncName: "CircleDTOMapper"}}
+	checkedMappers := map[[2]string]nestedMapper{{"model.Circle", "model.CircleDTO"}: {MapperFuncName: "CircleDTOMapper", ReturnsError: true}}
+	tests := []struct {
+		name     string
+		variants []variantConfig
+		options  castOptions
+		want     []variantCase
+		wantErr  bool
+	}{
+		{
+			name:     "Mapped and assigned variants",
+			variants: []variantConfig{circle, {Source: "model/shape.Square", Destination: "model/shape.Square"}},
+			options:  castOptions{mappers: mappers},
+			want:     []variantCase{{Type: "*model.Circle", CastStr: "CircleDTOMapper(v)"}, {Type: "*model.Square", CastStr: "v"}},
+		},
+		{
+			name:     "Variant field",
+			variants: []variantConfig{{Source: "model/shape.Wrap", Field: "Circle", Destination: "model/shape.CircleDTO"}},
+			options:  castOptions{mappers: mappers},
+			want:     []variantCase{{Type: "*model.Wrap", CastStr: "CircleDTOMapper(v.Circle)"}},
+		},
+		{
+			name:     "Mapper returning an error",
+			variants: []variantConfig{circle},
+			options:  castOptions{mappers: checkedMappers, returnsError: true},
+			want:     []variantCase{{Type: "*model.Circle", CastStr: "CircleDTOMapper(v)", Checked: true}},
+		},
+		{
+			name:     "Unknown form",
+			variants: []variantConfig{{Source: circle.Source, Destination: circle.Destination, Input: "reference"}},
+			options:  castOptions{mappers: mappers},
+			wantErr:  true,
+		},
+		{
+			name:     "Variant field missing",
+			variants: []variantConfig{{Source: "model/shape.Wrap", Field: "Square", Destination: "model/shape.CircleDTO"}},
+			options:  castOptions{mappers: mappers},
+			wantErr:  true,
+		},
+		{
+			name:     "Mapper missing",
+			variants: []variantConfig{circle},
+			wantErr:  true,
+		},
+		{
+			name:     "Mapper returning an error into a mapper returning none",
+			variants: []variantConfig{circle},
+			options:  castOptions{mappers: checkedMappers},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := variantCases(dir, dstField, tt.variants, tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("variantCases() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("variantCases() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fieldMappingRuleTemplate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule fieldMappingRule
+		want string
+	}{
+		{
+			name: "Guarded",
+			rule: fieldMappingRule{DstAlias: "dst", DstFieldName: "Email", CastStr: "*src.Email", Casted: true, Guard: "src.Email != nil"},
+			want: `
+if src.Email != nil {
+dst.Email = *src.Email
+}`,
+		},
+		{
+			name: "Guarded unmapped",
+			rule: fieldMappingRule{DstAlias: "dst", DstFieldName: "Email", CastStr: "src.Email", Guard: "src.Email != nil"},
+			want: `
+//dst.Email = src.Email`,
+		},
+	}
+	tmpl := template.Must(template.New("").Funcs(template.FuncMap{"ToTitle": strings.Title}).Parse(mapperTmpl))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tmpl.ExecuteTemplate(&buf, "fieldMappingRule", tt.rule); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("fieldMappingRule = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_variantsTemplate(t *testing.T) {
+	rule := fieldMappingRule{
+		MapperFuncName: "ShapeMapper",
+		DstAlias:       "dst",
+		DstFieldName:   "Shape",
+		CastStr:        "src.Shape",
+		Casted:         true,
+		Zero:           "nil",
+		Variants:       []variantCase{{Type: "*model.Circle", CastStr: "CircleDTOMapper(v)"}},
+	}
+	tests := []struct {
+		name       string
+		variants   []variantCase
+		unexpected bool
+		want       string
+	}{
+		{
+			name: "Unexpected variants left unset",
+			want: `
+switch v := src.Shape.(type) {
+case *model.Circle:
+	if v != nil {
+	dst.Shape = CircleDTOMapper(v)
+	}
+}`,
+		},
+		{
+			name:       "Unexpected variants returning an error",
+			unexpected: true,
+			want: `
+switch v := src.Shape.(type) {
+case *model.Circle:
+	if v != nil {
+	dst.Shape = CircleDTOMapper(v)
+	}
+case nil:
+default:
+	return nil, fmt.Errorf("ShapeMapper: Shape: unexpected %T", v)
+}`,
+		},
+		{
+			name:     "Value variant",
+			variants: []variantCase{{Type: "model.Square", CastStr: "SquareDTOMapper(&v)"}},
+			want: `
+switch v := src.Shape.(type) {
+case model.Square:
+	dst.Shape = SquareDTOMapper(&v)
+}`,
+		},
+	}
+	tmpl := template.Must(template.New("").Funcs(template.FuncMap{"ToTitle": strings.Title}).Parse(mapperTmpl))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := rule
+			rule.UnexpectedVariants = tt.unexpected
+			if tt.variants != nil {
+				rule.Variants = tt.variants
+			}
+			var buf bytes.Buffer
+			if err := tmpl.ExecuteTemplate(&buf, "fieldMappingRule", rule); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("fieldMappingRule = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_oneofFieldRules(t *testing.T) {
+	oneofs := map[string][]oneofWrapper{
+		"Contact": {
+			{Type: "pb.Member_Email", Field: field{Name: "Email", TypeStr: "string", Underlying: "string"}},
+			{Type: "pb.Member_Phone", Field: field{Name: "Phone", TypeStr: "string", Underlying: "string"}},
+		},
+	}
+	message := src{Alias: "src", ShortPath: "pb.Member", Fields: []field{{Name: "Contact", Ptr: true, TypeStr: "isMember_Contact"}}, Oneofs: oneofs}
+	flat := src{Alias: "src", ShortPath: "model.Member", Fields: []field{
+		{Name: "Email", Ptr: true, TypeStr: "*string", Underlying: "*string"},
+		{Name: "Phone", TypeStr: "string", Underlying: "string"},
+	}}
+	tests := []struct {
+		name    string
+		srcList []src
+		dst     src
+		want    map[string][]fieldMappingRule
+	}{
+		{
+			name:    "Oneof into flat fields",
+			srcList: []src{message},
+			dst:     src{Alias: "dst", Fields: flat.Fields},
+			want: map[string][]fieldMappingRule{
+				"Email": {{DstFieldName: "Email", SrcAlias: "src", SrcShortPath: "pb.Member", SrcFieldName: "Contact", CastStr: "src.Contact", Casted: true,
+					Variants: []variantCase{{Type: "*pb.Member_Email", CastStr: "mapping.Ptr(v.Email)", DstFieldName: "Email"}}}},
+				"Phone": {{DstFieldName: "Phone", SrcAlias: "src", SrcShortPath: "pb.Member", SrcFieldName: "Contact", CastStr: "src.Contact", Casted: true,
+					Variants: []variantCase{{Type: "*pb.Member_Phone", CastStr: "v.Phone", DstFieldName: "Phone"}}}},
+			},
+		},
+		{
+			name:    "Flat fields into oneof",
+			srcList: []src{flat},
+			dst:     src{Alias: "dst", Fields: message.Fields, Oneofs: oneofs},
+			want: map[string][]fieldMappingRule{
+				"Contact": {
+					{DstFieldName: "Contact", SrcAlias: "src", SrcShortPath: "model.Member", SrcFieldName: "Email", CastStr: "&pb.Member_Email{Email: *src.Email}", Casted: true,
+						Guard: "dst.Contact == nil && src.Email != nil"},
+					{DstFieldName: "Contact", SrcAlias: "src", SrcShortPath: "model.Member", SrcFieldName: "Phone", CastStr: "&pb.Member_Phone{Phone: src.Phone}", Casted: true,
+						Guard: `dst.Contact == nil && src.Phone != ""`},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := oneofFieldRules(tt.srcList, tt.dst, castOptions{}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("oneofFieldRules() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mergeOneofRules(t *testing.T) {
+	email := fieldMappingRule{DstFieldName: "Email", SrcAlias: "src", CastStr: "src.Contact", Casted: true,
+		Variants: []variantCase{{Type: "*pb.Member_Email", CastStr: "v.Email", DstFieldName: "Email"}}}
+	phone := fieldMappingRule{DstFieldName: "Phone", SrcAlias: "src", CastStr: "src.Contact", Casted: true,
+		Variants: []variantCase{{Type: "*pb.Member_Phone", CastStr: "v.Phone", DstFieldName: "Phone"}}}
+	guarded := phone
+	guarded.Guard = "src.Verified"
+	name := fieldMappingRule{DstFieldName: "Name", SrcAlias: "src", CastStr: "src.Name", Casted: true}
+	tests := []struct {
+		name  string
+		rules []fieldMappingRule
+		want  []fieldMappingRule
+	}{
+		{
+			name:  "Cases of a oneof",
+			rules: []fieldMappingRule{email, name, phone},
+			want: []fieldMappingRule{{DstFieldName: "Email", SrcAlias: "src", CastStr: "src.Contact", Casted: true,
+				Variants: append(email.Variants, phone.Variants...)}, name},
+		},
+		{name: "Guarded case", rules: []fieldMappingRule{email, guarded}, want: []fieldMappingRule{email, guarded}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeOneofRules(tt.rules); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeOneofRules() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// reservePackageAliases makes the structures of the test refer to the
+// packages by the aliases, the aliases reserved and the imports registered
+// before being restored once the test ends.
+func reservePackageAliases(t *testing.T, aliases map[string]string) {
+	reserved, imported := reservedPackageAliasMap, importPackageAliasMap
+	t.Cleanup(func() {
+		reservedPackageAliasMap, importPackageAliasMap = reserved, imported
+	})
+	reservedPackageAliasMap, importPackageAliasMap = aliases, make(map[string]string)
+}
+
+func Test_castArray(t *testing.T) {
+	reservePackageAliases(t, map[string]string{"uuid": uuidPackage})
+	tests := []struct {
+		name    string
+		srcType string
+		dstType string
+		want    string
+		ok      bool
+	}{
+		{name: "Array into slice", srcType: "[16]byte", dstType: "[]byte", want: "func(s [16]byte) []byte { return s[:] }(src.ID)", ok: true},
+		{name: "Slice into array", srcType: "[]byte", dstType: "[16]byte", want: "src.ID"},
+		{name: "Array into uuid", srcType: "[16]byte", dstType: "uuid.UUID", want: "uuid.UUID(src.ID)", ok: true},
+		{
+			name:    "Element-wise",
+			srcType: "[3]int64",
+			dstType: "[3]int32",
+			want:    "func(s [3]int64) (d [3]int32) { for i, v := range s { d[i] = int32(v) }; return d }(src.ID)",
+			ok:      true,
+		},
+		{name: "Length mismatch", srcType: "[3]int64", dstType: "[4]int64", want: "src.ID"},
+		{name: "Slices", srcType: "[]int64", dstType: "[]int32", want: "src.ID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := castArray("src.ID", tt.srcType, tt.dstType, castOptions{})
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("castArray() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func Test_castUUIDField(t *testing.T) {
+	tests := []struct {
+		name        string
+		aliases     map[string]string
+		srcField    field
+		dstField    field
+		options     castOptions
+		want        fieldMappingRule
+		wantCast    bool
+		wantImports map[string]string
+	}{
+		{
+			name:     "Format",
+			aliases:  map[string]string{"uuid": uuidPackage},
+			srcField: field{Name: "ID", TypeStr: "uuid.UUID"},
+			dstField: field{Name: "ID", TypeStr: "string"},
+			options:  castOptions{uuidStrings: true},
+			want:     fieldMappingRule{CastStr: "src.ID.String()", Casted: true},
+			wantCast: true,
+		},
+		{
+			name:        "Parse",
+			aliases:     map[string]string{"uuid": uuidPackage},
+			srcField:    field{Name: "ID", TypeStr: "string", Underlying: "string"},
+			dstField:    field{Name: "ID", TypeStr: "*uuid.UUID"},
+			options:     castOptions{uuidStrings: true, returnsError: true},
+			want:        fieldMappingRule{CastStr: "uuid.Parse(src.ID)", Guard: "src.ID != \"\"", CastAddr: true, Checked: true, Casted: true},
+			wantCast:    true,
+			wantImports: map[string]string{"uuid": uuidPackage},
+		},
+		{
+			name:        "Parse by the alias of another uuid package",
+			aliases:     map[string]string{"uuid": "github.com/gofrs/uuid", "uuid1": uuidPackage},
+			srcField:    field{Name: "ID", TypeStr: "*string", Underlying: "*string"},
+			dstField:    field{Name: "ID", TypeStr: "uuid1.UUID"},
+			options:     castOptions{uuidStrings: true, returnsError: true},
+			want:        fieldMappingRule{CastStr: "uuid1.Parse(*src.ID)", Checked: true, Casted: true},
+			wantCast:    true,
+			wantImports: map[string]string{"uuid1": uuidPackage},
+		},
+		{
+			name:     "Another uuid package",
+			aliases:  map[string]string{"uuid": "github.com/gofrs/uuid"},
+			srcField: field{Name: "ID", TypeStr: "string", Underlying: "string"},
+			dstField: field{Name: "ID", TypeStr: "uuid.UUID"},
+			options:  castOptions{uuidStrings: true, returnsError: true},
+		},
+		{
+			name:     "Mapper returning no error",
+			aliases:  map[string]string{"uuid": uuidPackage},
+			srcField: field{Name: "ID", TypeStr: "string", Underlying: "string"},
+			dstField: field{Name: "ID", TypeStr: "uuid.UUID"},
+			options:  castOptions{uuidStrings: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reservePackageAliases(t, tt.aliases)
+			got, ok := castUUIDField(fieldMappingRule{}, "src", tt.srcField, tt.dstField, tt.options)
+			if !reflect.DeepEqual(got, tt.want) || ok != tt.wantCast {
+				t.Errorf("castUUIDField() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantCast)
+			}
+			if len(tt.wantImports) == 0 {
+				tt.wantImports = map[string]string{}
+			}
+			if !reflect.DeepEqual(importPackageAliasMap, tt.wantImports) {
+				t.Errorf("castUUIDField() imports = %v, want %v", importPackageAliasMap, tt.wantImports)
+			}
+		})
+	}
+}
+
+func Test_useArrayImports(t *testing.T) {
+	tests := []struct {
+		name    string
+		aliases map[string]string
+		types   []string
+		want    map[string]string
+	}{
+		{name: "Array of uuids", aliases: map[string]string{"uuid": uuidPackage}, types: []string{"[]byte", "[]*uuid.UUID"}, want: map[string]string{"uuid": uuidPackage}},
+		{name: "Other uuid package", aliases: map[string]string{"uuid": "github.com/gofrs/uuid"}, types: []string{"uuid.UUID"}, want: map[string]string{}},
+		{name: "Type of the same suffix", aliases: map[string]string{"uuid": uuidPackage}, types: []string{"myuuid.UUID"}, want: map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reservePackageAliases(t, tt.aliases)
+			useArrayImports(tt.types...)
+			if !reflect.DeepEqual(importPackageAliasMap, tt.want) {
+				t.Errorf("useArrayImports() imports = %v, want %v", importPackageAliasMap, tt.want)
+			}
+		})
+	}
+}
+
+func Test_castSliceArrayField(t *testing.T) {
+	tests := []struct {
+		name     string
+		srcType  string
+		dstType  string
+		options  castOptions
+		want     fieldMappingRule
+		wantCast bool
+	}{
+		{
+			name:    "Checked",
+			srcType: "[]byte",
+			dstType: "[16]byte",
+			options: castOptions{returnsError: true},
+			want: fieldMappingRule{
+				CastStr: "func(s []byte) (d [16]byte, err error) { if len(s) != len(d) { return d, fmt.Errorf(\"%d elements can't be converted to %T\", len(s), d) }; copy(d[:], s); return d, nil }(src.ID)",
+				Checked: true,
+				Casted:  true,
+			},
+			wantCast: true,
+		},
+		{name: "Mapper returning no error", srcType: "[]byte", dstType: "[16]byte"},
+		{name: "Array into array", srcType: "[16]byte", dstType: "[16]byte", options: castOptions{returnsError: true}},
+		{name: "Slice into slice", srcType: "[]byte", dstType: "[]byte", options: castOptions{returnsError: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := castSliceArrayField(fieldMappingRule{}, "src", field{Name: "ID", TypeStr: tt.srcType}, field{Name: "ID", TypeStr: tt.dstType}, tt.options)
+			if !reflect.DeepEqual(got, tt.want) || ok != tt.wantCast {
+				t.Errorf("castSliceArrayField() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantCast)
+			}
+		})
+	}
+}
+
+func Test_castStructpbField(t *testing.T) {
+	srcField := field{Name: "Meta", Ptr: true, TypeStr: "map[string]any", Underlying: "map[string]any"}
+	tests := []struct {
+		name     string
+		dstType  string
+		options  castOptions
+		want     fieldMappingRule
+		wantCast bool
+	}{
+		{
+			name:     "Checked",
+			dstType:  "*structpb.Struct",
+			options:  castOptions{returnsError: true},
+			want:     fieldMappingRule{CastStr: "structpb.NewStruct(src.Meta)", Checked: true, Casted: true},
+			wantCast: true,
+		},
+		{name: "Mapper returning no error", dstType: "*structpb.Struct"},
+		{name: "Incompatible message", dstType: "*structpb.ListValue", options: castOptions{returnsError: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := castStructpbField(fieldMappingRule{}, "src", srcField, field{Name: "Meta", TypeStr: tt.dstType}, tt.options)
+			if !reflect.DeepEqual(got, tt.want) || ok != tt.wantCast {
+				t.Errorf("castStructpbField() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantCast)
+			}
+		})
+	}
+}
+
+func Test_qualifiedTypeStr(t *testing.T) {
+	reservePackageAlias("example.com/first/ids")
+	tests := []struct {
+		name    string
+		expr    string
+		imports map[string]string
+		want    string
+	}{
+		{name: "Aliased import", expr: "*wpb.StringValue", imports: map[string]string{"wpb": wrapperspbPackage}, want: "*wrapperspb.StringValue"},
+		{name: "Same package name", expr: "ids.ID", imports: map[string]string{"ids": "example.com/second/ids"}, want: "ids1.ID"},
+		{name: "Major version", expr: "[]guid.GUID", imports: map[string]string{"guid": "example.com/acme/guid/v5"}, want: "[]guid.GUID"},
+		{name: "Unresolved", expr: "other.Type", want: "other.Type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := qualifiedTypeStr(expr, typeScope{imports: tt.imports}); got != tt.want {
+				t.Errorf("qualifiedTypeStr() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_collectConverters(t *testing.T) {
+	user := mappingParams{MapperFuncName: "UserMapper", ListMapperFuncName: "UserListMapper", MapMapperFuncName: "UserMapMapper"}
+	team := mappingParams{MapperFuncName: "TeamMapper", ListMapperFuncName: "TeamListMapper", IndexMapperFuncName: "TeamIndexMapper", Index: &indexParams{Key: "v.ID", KeyType: "int64"}}
+	tests := []struct {
+		name          string
+		mapperConfigs []mapperConfig
+		mappers       []mappingParams
+		want          []converterParams
+	}{
+		{
+			name:          "No converter",
+			mapperConfigs: []mapperConfig{{}},
+			mappers:       []mappingParams{user},
+		},
+		{
+			name:          "Shared converter",
+			mapperConfigs: []mapperConfig{{Converter: "Converter"}, {}, {Converter: "Converter"}},
+			mappers:       []mappingParams{user, {MapperFuncName: "OrderMapper"}, team},
+			want: []converterParams{{
+				Name:        "Converter",
+				ImplName:    "ConverterImpl",
+				Constructor: "NewConverter",
+				Declare:     true,
+				Methods: []converterMethod{
+					{Name: "UserMapper", FuncName: "UserMapper", Kind: kindMapper, Mapper: user},
+					{Name: "UserListMapper", FuncName: "UserListMapper", Kind: kindListMapper, Mapper: user},
+					{Name: "TeamMapper", FuncName: "TeamMapper", Kind: kindMapper, Mapper: team},
+					{Name: "TeamListMapper", FuncName: "TeamListMapper", Kind: kindListMapper, Mapper: team},
+					{Name: "TeamIndexMapper", FuncName: "TeamIndexMapper", Kind: kindIndexMapper, Mapper: team},
+				},
+			}},
+		},
+		{
+			name: "Interface methods",
+			mapperConfigs: []mapperConfig{
+				{Converter: "Reader", converterInterface: "store.Reader", converterImpl: "readerImpl", method: "User"},
+				{Converter: "Reader", converterInterface: "store.Reader", converterImpl: "readerImpl", method: "Teams", listMethod: true},
+			},
+			mappers: []mappingParams{user, team},
+			want: []converterParams{{
+				Name:        "store.Reader",
+				ImplName:    "readerImpl",
+				Constructor: "NewReader",
+				Methods: []converterMethod{
+					{Name: "User", FuncName: "UserMapper", Kind: kindMapper, Mapper: user},
+					{Name: "Teams", FuncName: "TeamListMapper", Kind: kindListMapper, Mapper: team},
+				},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := collectConverters(tt.mapperConfigs, tt.mappers); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("collectConverters() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_converterTemplate(t *testing.T) {
+	user := mappingParams{
+		MapperFuncName:     "UserMapper",
+		ListMapperFuncName: "UserListMapper",
+		MapMapperFuncName:  "UserMapMapper",
+		SrcList:            []src{{Alias: "src", ShortPath: "model.User"}},
+		Dst:                src{Alias: "dst", ShortPath: "pb.User"},
+		InputPtr:           "*",
+		OutputPtr:          "*",
+		ListInputPtr:       "*",
+		ListOutputPtr:      "*",
+		ContextParam:       "ctx context.Context",
+		Params:             []paramConfig{{Name: "tenantID", Type: "string"}},
+	}
+	team := mappingParams{
+		MapperFuncName:      "TeamMapper",
+		ListMapperFuncName:  "TeamListMapper",
+		IndexMapperFuncName: "TeamIndexMapper",
+		Index:               &indexParams{Key: "v.ID", KeyType: "int64"},
+		SrcList:             []src{{Alias: "src", ShortPath: "model.Team"}},
+		Dst:                 src{Alias: "dst", ShortPath: "pb.Team"},
+		ReturnsError:        true,
+		ListReturnsError:    true,
+		IndexReturnsError:   true,
+		ListOutputPtr:       "*",
+	}
+	tests := []struct {
+		name          string
+		mapperConfigs []mapperConfig
+		mappers       []mappingParams
+		want          string
+	}{
+		{
+			name:          "Declared converter",
+			mapperConfigs: []mapperConfig{{Converter: "Converter"}, {Converter: "Converter"}},
+			mappers:       []mappingParams{user, team},
+			want: `
+// Converter converts with the UserMapper, UserListMapper, TeamMapper, TeamListMapper, TeamIndexMapper mappers.
+type Converter interface {
+	UserMapper(ctx context.Context, src *model.User, tenantID string) (dst *pb.User)
+	UserListMapper(ctx context.Context, src []*model.User, tenantID string) (dst []*pb.User)
+	TeamMapper(src model.Team) (dst pb.Team, err error)
+	TeamListMapper(src []model.Team) (dst []*pb.Team, err error)
+	TeamIndexMapper(src []model.Team) (dst map[int64]*pb.Team, err error)
+}
+
+// ConverterImpl implements Converter with the generated mappers.
+type ConverterImpl struct{}
+
+var _ Converter = ConverterImpl{}
+
+// NewConverter returns the generated Converter.
+func NewConverter() Converter {
+	return ConverterImpl{}
+}
+func (ConverterImpl) UserMapper(ctx context.Context, src *model.User, tenantID string) (dst *pb.User) {
+	return UserMapper(ctx, src, tenantID)
+}
+func (ConverterImpl) UserListMapper(ctx context.Context, src []*model.User, tenantID string) (dst []*pb.User) {
+	return UserListMapper(ctx, src, tenantID)
+}
+func (ConverterImpl) TeamMapper(src model.Team) (dst pb.Team, err error) {
+	return TeamMapper(src)
+}
+func (ConverterImpl) TeamListMapper(src []model.Team) (dst []*pb.Team, err error) {
+	return TeamListMapper(src)
+}
+func (ConverterImpl) TeamIndexMapper(src []model.Team) (dst map[int64]*pb.Team, err error) {
+	return TeamIndexMapper(src)
+}`,
+		},
+		{
+			name:          "Interface implementation",
+			mapperConfigs: []mapperConfig{{Converter: "Reader", converterInterface: "store.Reader", converterImpl: "readerImpl", method: "Teams", listMethod: true}},
+			mappers:       []mappingParams{team},
+			want: `
+// readerImpl implements store.Reader with the generated mappers.
+type readerImpl struct{}
+
+var _ store.Reader = readerImpl{}
+
+// NewReader returns the generated store.Reader.
+func NewReader() store.Reader {
+	return readerImpl{}
+}
+func (readerImpl) Teams(src []model.Team) (dst []*pb.Team, err error) {
+	return TeamListMapper(src)
+}`,
+		},
+	}
+	tmpl := template.Must(template.New("").Funcs(template.FuncMap{"ToTitle": strings.Title}).Parse(mapperTmpl))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := tmpl.Execute(&buf, struct {
+				Timestamp       time.Time
+				ConfPath        string
+				PackageName     string
+				ImportPackages  []importPackage
+				Mappers         []mappingParams
+				InlineHelpers   bool
+				TypeToPtrList   []string
+				TypesCastList   []typesCast
+				CheckedCastList []checkedCast
+				Converters      []converterParams
+			}{
+				PackageName: "out",
+				Converters:  collectConverters(tt.mapperConfigs, tt.mappers),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); !strings.HasSuffix(got, tt.want) {
+				t.Errorf("mapperTmpl = %v, want converters %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newTypePathResolver writes a module declaring model.User, model.View and
+// the generic api.Page and returns the resolver of a file of its api
+// package importing context and model.
+func newTypePathResolver(t *testing.T) typePathResolver {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":        "module example.com/app\n",
+		"model/user.go": "package model\n\ntype User struct{}\n\ntype View struct{}\n",
+		"api/page.go":   "package api\n\ntype Page[T any] struct{ Items []T }\n",
+	}
+	for name, data := range files {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return typePathResolver{
+		dir:     dir,
+		fileDir: filepath.Join(dir, "api"),
+		imports: map[string]string{"context": "context", "model": "example.com/app/model"},
+	}
+}
+
+func Test_typePathResolver(t *testing.T) {
+	resolver := newTypePathResolver(t)
+	tests := []struct {
+		name     string
+		typeExpr string
+		want     string
+		wantErr  bool
+	}{
+		{name: "Predeclared", typeExpr: "int64", want: "int64"},
+		{name: "Imported", typeExpr: "model.User", want: "model/user.User"},
+		{name: "Same package", typeExpr: "Page", want: "api/page.Page"},
+		{name: "Instance", typeExpr: "Page[model.User]", want: "api/page.Page[model/user.User]"},
+		{name: "Not declared", typeExpr: "model.Team", wantErr: true},
+		{name: "Not imported", typeExpr: "store.User", wantErr: true},
+		{name: "Pointer", typeExpr: "*model.User", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.typeExpr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := resolver.typePath(expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("typePath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("typePath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_methodMapper(t *testing.T) {
+	resolver := newTypePathResolver(t)
+	user := sourceConfig{Alias: "user", Path: "model/user.User"}
+	view := sourceConfig{Alias: "dst", Path: "model/user.View"}
+	tests := []struct {
+		name     string
+		funcType string
+		want     mapperConfig
+		wantErr  bool
+	}{
+		{
+			name:     "Pointers",
+			funcType: "func(user *model.User) *model.View",
+			want:     mapperConfig{Sources: []sourceConfig{user}, Destination: view},
+		},
+		{
+			name:     "Value lists",
+			funcType: "func(user []model.User) ([]model.View, error)",
+			want:     mapperConfig{Sources: []sourceConfig{user}, Destination: view, Input: signatureValue, Output: signatureValue, Errors: true, listMethod: true},
+		},
+		{
+			name:     "Context and extra parameters",
+			funcType: "func(ctx context.Context, user *model.User, tenantID string, limit, offset int) model.View",
+			want: mapperConfig{
+				Sources:     []sourceConfig{user},
+				Destination: view,
+				Output:      signatureValue,
+				Context:     true,
+				Params:      []paramConfig{{Name: "tenantID", Type: "string"}, {Name: "limit", Type: "int"}, {Name: "offset", Type: "int"}},
+			},
+		},
+		{
+			name:     "Unnamed parameters",
+			funcType: "func(*model.User, *model.User) *model.View",
+			want:     mapperConfig{Sources: []sourceConfig{{Alias: "src", Path: user.Path}, {Alias: "src1", Path: user.Path}}, Destination: view},
+		},
+		{name: "No parameter", funcType: "func() *model.View", wantErr: true},
+		{name: "Several contexts", funcType: "func(a, b context.Context, user *model.User) *model.View", wantErr: true},
+		{name: "Mixed parameters", funcType: "func(user *model.User, users []*model.User) *model.View", wantErr: true},
+		{name: "List into structure", funcType: "func(users []*model.User) *model.View", wantErr: true},
+		{name: "Second result not an error", funcType: "func(user *model.User) (*model.View, bool)", wantErr: true},
+		{name: "Several results", funcType: "func(user *model.User) (*model.View, *model.View, error)", wantErr: true},
+		{name: "Unknown type", funcType: "func(user *model.Team) *model.View", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.funcType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := methodMapper(expr.(*ast.FuncType), resolver)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("methodMapper() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("methodMapper() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_generateFixture generates the mappers of the testdata/compile
+// module, then vets it and runs its tests against the generated file and
+// the mapping package of this module.
+func Test_generateFixture(t *testing.T) {
+	goEnv, err := exec.Command("go", "env", "GOPATH").Output()
+	if err != nil {
+		t.Skipf("go command unavailable: %v", err)
+	}
+	repoDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gopath := t.TempDir()
+	moduleDir := filepath.Join(gopath, "src", "example")
+	err = filepath.Walk(filepath.Join("testdata", "compile"), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(filepath.Join("testdata", "compile"), path)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Join(moduleDir, filepath.Dir(rel)), os.ModePerm); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(moduleDir, rel), data, 0o644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	goMod := fmt.Sprintf("module example\n\ngo 1.18\n\nrequire github.com/Rustavil/mapstruct v0.0.0\n\nreplace github.com/Rustavil/mapstruct => %s\n", repoDir)
+	if err := ioutil.WriteFile(filepath.Join(moduleDir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("GOPATH", gopath)
+	reservePackageAliases(t, map[string]string{})
+	mappersConfig := loadConfig(filepath.Join(moduleDir, "mappers.yml"))
+	mappersConfig.out = filepath.Join(moduleDir, "out", "mappers_gen.go")
+	params, err := params(mappersConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	tmpl := template.Must(template.New("").Funcs(template.FuncMap{"ToTitle": strings.Title}).Parse(mapperTmpl))
+	if err := tmpl.Execute(&buf, params); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(mappersConfig.out, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{{"vet", "./..."}, {"test", "./..."}} {
+		cmd := exec.Command("go", args...)
+		cmd.Dir = moduleDir
+		cmd.Env = append(os.Environ(), "GOPATH="+strings.TrimSpace(string(goEnv)), "GOFLAGS=-mod=mod", "GOWORK=off")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("go %s: %v\n%s\n%s", strings.Join(args, " "), err, output, buf.String())
+		}
+	}
+}
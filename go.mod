@@ -0,0 +1,5 @@
+module github.com/Rustavil/mapstruct
+
+go 1.18
+
+require gopkg.in/yaml.v2 v2.4.0
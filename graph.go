@@ -0,0 +1,70 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// graphParams shapes the variant of a mapper threading the graph of the
+// structures mapped so far, New is the expression creating that graph.
+type graphParams struct {
+	FuncName string
+	Type     string
+	New      string
+	Cycles   bool
+}
+
+// GraphFuncName names the variant of the mapper taking the graph of the
+// structures mapped so far, empty unless the mapper is cycle-aware or
+// depth limited.
+func (mc mapperConfig) GraphFuncName() string {
+	if !mc.Cycles && mc.MaxDepth == 0 {
+		return ""
+	}
+	return lowerFirst(mc.MapperName()) + "Graph"
+}
+
+// graphMapperParams validates the cycle-aware and the depth limited mapper
+// and returns the params of its graph variant, nil when it has none.
+func graphMapperParams(mapperConfig mapperConfig, inlineHelpers bool) (*graphParams, error) {
+	if mapperConfig.MaxDepth < 0 {
+		return nil, fmt.Errorf("mapper %s: negative max depth %d", mapperConfig.MapperName(), mapperConfig.MaxDepth)
+	}
+	if len(mapperConfig.GraphFuncName()) == 0 {
+		return nil, nil
+	}
+	switch {
+	case len(mapperConfig.Sources) != 1:
+		return nil, fmt.Errorf("mapper %s: cycle-aware mapping requires a single source", mapperConfig.MapperName())
+	case mapperConfig.InputValue() || mapperConfig.OutputValue():
+		return nil, fmt.Errorf("mapper %s: cycle-aware mapping requires pointer input and output", mapperConfig.MapperName())
+	case inlineHelpers:
+		return nil, fmt.Errorf("mapper %s: cycle-aware mapping requires runtime helpers", mapperConfig.MapperName())
+	}
+	mappingAlias := useImport("mapping", mappingPackage)
+	return &graphParams{
+		FuncName: mapperConfig.GraphFuncName(),
+		Type:     mappingAlias + ".Graph",
+		New:      fmt.Sprintf("%s.NewGraph(%d)", mappingAlias, mapperConfig.MaxDepth),
+		Cycles:   mapperConfig.Cycles,
+	}, nil
+}
+
+// nestedMapper returns the mapper of the structure types, its graph
+// variant when the calling mapper threads a graph it can share. Mappers
+// taking the context or extra parameters are only found for callers having
+// them.
+func (options castOptions) nestedMapper(srcType, dstType string) (nestedMapper, bool) {
+	mapper, exist := options.mappers[[2]string{strings.TrimPrefix(srcType, "*"), strings.TrimPrefix(dstType, "*")}]
+	if exist && ((mapper.Context && !options.context) || !hasParams(options.params, mapper.Params)) {
+		return mapper, false
+	}
+	if exist && options.graph && len(mapper.GraphFuncName) != 0 {
+		mapper.MapperFuncName, mapper.ListMapperFuncName, mapper.Graph = mapper.GraphFuncName, "", true
+	}
+	return mapper, exist
+}
@@ -0,0 +1,84 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	mappingPackage = "github.com/Rustavil/mapstruct/mapping"
+
+	helpersRuntime = "runtime"
+	helpersInline  = "inline"
+)
+
+// ptrStr returns an expression taking the address of a copy of the value.
+func ptrStr(typeStr, valueStr string, options castOptions) string {
+	if !options.inlineHelpers {
+		return fmt.Sprintf("%s.Ptr(%s)", useImport("mapping", mappingPackage), valueStr)
+	}
+	for _, t := range typeToPtrList {
+		if t == typeStr {
+			return fmt.Sprintf("%sPtr(%s)", typeStr, valueStr)
+		}
+	}
+	return fmt.Sprintf("func(v %s) *%s { return &v }(%s)", typeStr, typeStr, valueStr)
+}
+
+// derefStr returns an expression of the value the pointer points to, the
+// zero value for nil.
+func derefStr(typeStr, ptrStr string, options castOptions) string {
+	if !options.inlineHelpers {
+		return fmt.Sprintf("%s.Deref(%s)", useImport("mapping", mappingPackage), ptrStr)
+	}
+	return fmt.Sprintf("func(p *%s) (v %s) { if p != nil { v = *p }; return v }(%s)", typeStr, typeStr, ptrStr)
+}
+
+// castSlice converts slices of numeric types, of booleans or strings and
+// of pointers to them.
+func castSlice(srcRow, srcType, dstType string, options castOptions) (string, bool) {
+	srcElem, dstElem := strings.TrimPrefix(srcType, "[]"), strings.TrimPrefix(dstType, "[]")
+	srcPtr, dstPtr := strings.HasPrefix(srcElem, "*"), strings.HasPrefix(dstElem, "*")
+	srcBase, dstBase := strings.TrimPrefix(srcElem, "*"), strings.TrimPrefix(dstElem, "*")
+	_, srcNumeric := numericTypeBits[srcBase]
+	_, dstNumeric := numericTypeBits[dstBase]
+	if srcBase != dstBase && !(srcNumeric && dstNumeric) {
+		return srcRow, false
+	}
+	if options.inlineHelpers {
+		srcName, dstName := srcBase+"Arr", strings.Title(dstBase)+"Arr"
+		if srcPtr {
+			srcName = srcBase + "PtrArr"
+		}
+		if dstPtr {
+			dstName = strings.Title(dstBase) + "PtrArr"
+		}
+		return fmt.Sprintf("%sTo%s(%s)", srcName, dstName, srcRow), true
+	}
+
+	mapping := useImport("mapping", mappingPackage)
+	if srcPtr && dstPtr {
+		return fmt.Sprintf("%s.MapSlice(%s, %s.ConvertPtr[%s, %s])", mapping, srcRow, mapping, srcBase, dstBase), true
+	}
+	castStr := srcRow
+	if srcPtr {
+		castStr = fmt.Sprintf("%s.DerefSlice(%s)", mapping, castStr)
+	}
+	if srcBase != dstBase {
+		castStr = fmt.Sprintf("%s.ConvertSlice[%s, %s](%s)", mapping, srcBase, dstBase, castStr)
+	}
+	if dstPtr {
+		castStr = fmt.Sprintf("%s.PtrSlice(%s)", mapping, castStr)
+	}
+	return castStr, true
+}
+
+// mapSliceStr maps the slice elements with castStr, an expression of v.
+func mapSliceStr(srcRow, srcElem, dstElem, castStr string) string {
+	return fmt.Sprintf("%s.MapSlice(%s, func(v %s) %s { return %s })",
+		useImport("mapping", mappingPackage), srcRow, srcElem, dstElem, castStr)
+}
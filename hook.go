@@ -0,0 +1,108 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// hookParams is the call of a before or an after hook, guarded by Guard
+// when set. Hooks of error returning mappers return an error the mapper
+// returns along with Zero.
+type hookParams struct {
+	Call         string
+	Guard        string
+	ReturnsError bool
+	Zero         string
+	Label        string
+}
+
+// mapperHooks returns the hooks of the mapper, nil for the hooks not
+// configured. Hooks take the context of context-aware mappers, the sources
+// and, after mapping, the destination, all of them by pointer so hooks can
+// normalize the sources and derive destination fields, then the extra
+// parameters of the mapper.
+func mapperHooks(mapperConfig mapperConfig, srcList []src, zero string) (before, after *hookParams) {
+	var args []string
+	if mapperConfig.Context {
+		args = append(args, contextArg)
+	}
+	for _, srcStruct := range srcList {
+		args = append(args, signatureAddr(mapperConfig.InputValue())+srcStruct.Alias)
+	}
+	if len(mapperConfig.Before) != 0 {
+		before = newHook(mapperConfig.Before, withParams(args, mapperConfig.Params), sourcesGuard(mapperConfig, srcList), mapperConfig.ReturnsError(), zero, mapperConfig.MapperName()+": before")
+	}
+	if len(mapperConfig.After) != 0 {
+		args = append(args, signatureAddr(mapperConfig.OutputValue())+mapperConfig.Destination.Alias)
+		after = newHook(mapperConfig.After, withParams(args, mapperConfig.Params), mappedGuard(mapperConfig, srcList), mapperConfig.ReturnsError(), zero, mapperConfig.MapperName()+": after")
+	}
+	return before, after
+}
+
+// sourcesGuard returns the condition a source is given, empty when the
+// mapper takes values.
+func sourcesGuard(mapperConfig mapperConfig, srcList []src) string {
+	if mapperConfig.InputValue() {
+		return ""
+	}
+	srcGuards := make([]string, 0, len(srcList))
+	for _, srcStruct := range srcList {
+		srcGuards = append(srcGuards, fmt.Sprintf("%s != nil", srcStruct.Alias))
+	}
+	return strings.Join(srcGuards, " || ")
+}
+
+// mappedGuard returns the condition the destination is mapped.
+func mappedGuard(mapperConfig mapperConfig, srcList []src) string {
+	if !mapperConfig.OutputValue() {
+		return fmt.Sprintf("%s != nil", mapperConfig.Destination.Alias)
+	}
+	return sourcesGuard(mapperConfig, srcList)
+}
+
+// listHooks returns the hooks of the list mapper, called with the source
+// lists and, after mapping, the destination list, then the extra
+// parameters.
+func listHooks(mapperConfig mapperConfig, srcList []src) (before, after *hookParams) {
+	var args []string
+	if mapperConfig.Context {
+		args = append(args, contextArg)
+	}
+	for _, srcStruct := range srcList {
+		args = append(args, srcStruct.Alias)
+	}
+	if len(mapperConfig.BeforeList) != 0 {
+		before = newHook(mapperConfig.BeforeList, withParams(args, mapperConfig.Params), "", mapperConfig.ListReturnsError(), "nil", mapperConfig.ListMapperName()+": before")
+	}
+	if len(mapperConfig.AfterList) != 0 {
+		args = append(args, mapperConfig.Destination.Alias)
+		after = newHook(mapperConfig.AfterList, withParams(args, mapperConfig.Params), "", mapperConfig.ListReturnsError(), "nil", mapperConfig.ListMapperName()+": after")
+	}
+	return before, after
+}
+
+// withParams returns the hook arguments followed by the extra parameters.
+func withParams(args []string, params []paramConfig) []string {
+	withParams := append([]string(nil), args...)
+	for _, param := range params {
+		withParams = append(withParams, param.Name)
+	}
+	return withParams
+}
+
+func newHook(funcName string, args []string, guard string, returnsError bool, zero, label string) *hookParams {
+	if returnsError {
+		useImport("fmt", "fmt")
+	}
+	return &hookParams{
+		Call:         fmt.Sprintf("%s(%s)", funcName, strings.Join(args, ", ")),
+		Guard:        guard,
+		ReturnsError: returnsError,
+		Zero:         zero,
+		Label:        label,
+	}
+}
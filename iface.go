@@ -0,0 +1,373 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// interfaceConfig points at a Go interface, every method of it becomes a
+// mapper of its parameters into its result and the generated type
+// implements the interface.
+type interfaceConfig struct {
+	Path string `yaml:"path"`
+	// Name is the implementing type, the interface name followed by Impl
+	// by default.
+	Name string `yaml:"name"`
+}
+
+// interfaceMappers reads the interface and returns the mappers
+// implementing its methods.
+func interfaceMappers(dir string, interfaceConfig interfaceConfig) ([]mapperConfig, error) {
+	i := strings.LastIndex(interfaceConfig.Path, ".")
+	if i <= 0 {
+		return nil, fmt.Errorf("interface path \"%s\" incorrect", interfaceConfig.Path)
+	}
+	interfaceName := interfaceConfig.Path[i+1:]
+	data, fileLocation, filePath, err := readSourceFile(dir, interfaceConfig.Path[:i]+".go")
+	if err != nil {
+		return nil, err
+	}
+	fileAST, err := parser.ParseFile(token.NewFileSet(), "", data, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var interfaceType *ast.InterfaceType
+	if o, exist := fileAST.Scope.Objects[interfaceName]; exist {
+		if ts, ok := o.Decl.(*ast.TypeSpec); ok {
+			interfaceType, _ = ts.Type.(*ast.InterfaceType)
+		}
+	}
+	if interfaceType == nil {
+		return nil, fmt.Errorf("interface %s not found in file %s", interfaceName, filePath)
+	}
+
+	resolver := typePathResolver{
+		dir:     dir,
+		fileDir: filepath.Dir(fileLocation),
+		imports: fileImports(fileAST),
+	}
+	implName := interfaceConfig.Name
+	if len(implName) == 0 {
+		implName = interfaceName + "Impl"
+	}
+	converterInterface := qualifiedName(getPackageAlias(parseImportPackagePath(filePath)), interfaceName)
+	var mappers []mapperConfig
+	for _, method := range interfaceType.Methods.List {
+		funcType, ok := method.Type.(*ast.FuncType)
+		if !ok || len(method.Names) == 0 {
+			return nil, fmt.Errorf("interface %s: embedded interfaces aren't supported", interfaceName)
+		}
+		methodName := method.Names[0].Name
+		mapperConfig, err := methodMapper(funcType, resolver)
+		if err != nil {
+			return nil, fmt.Errorf("interface %s: method %s: %w", interfaceName, methodName, err)
+		}
+		mapperConfig.Alias = lowerFirst(interfaceName) + methodName
+		mapperConfig.Converter = interfaceName
+		mapperConfig.converterInterface = converterInterface
+		mapperConfig.converterImpl = implName
+		mapperConfig.method = methodName
+		for _, directive := range parseDirectives(method.Doc) {
+			if err := applyMapperDirective(&mapperConfig, directive); err != nil {
+				return nil, fmt.Errorf("interface %s: method %s: %w", interfaceName, methodName, err)
+			}
+		}
+		returnsError := mapperConfig.ReturnsError()
+		if mapperConfig.listMethod {
+			returnsError = mapperConfig.ListReturnsError()
+		}
+		if returnsError && !mapperConfig.Errors {
+			return nil, fmt.Errorf("interface %s: method %s: must return an error", interfaceName, methodName)
+		}
+		mappers = append(mappers, mapperConfig)
+	}
+	return mappers, nil
+}
+
+// methodMapper builds the mapper of the method parameters into its result,
+// either of them being structures or lists of structures. A leading
+// context.Context parameter makes the mapper context-aware, parameters of
+// predeclared types following the first one are its extra parameters.
+func methodMapper(funcType *ast.FuncType, resolver typePathResolver) (mapperConfig, error) {
+	var mapperConfig mapperConfig
+	var listForm, valueForm bool
+	params := funcType.Params.List
+	if len(params) != 0 && isContextType(params[0].Type, resolver.imports) {
+		if len(params[0].Names) > 1 {
+			return mapperConfig, fmt.Errorf("several context parameters")
+		}
+		mapperConfig.Context = true
+		params = params[1:]
+	}
+	for i, param := range params {
+		if ident, ok := param.Type.(*ast.Ident); ok && predeclaredTypes[ident.Name] && i != 0 {
+			for _, name := range param.Names {
+				mapperConfig.Params = append(mapperConfig.Params, paramConfig{Name: name.Name, Type: ident.Name})
+			}
+			if len(param.Names) == 0 {
+				return mapperConfig, fmt.Errorf("unnamed %s parameter", ident.Name)
+			}
+			continue
+		}
+		list, value, typeExpr := methodTypeForm(param.Type)
+		if i == 0 {
+			listForm, valueForm = list, value
+		} else if list != listForm || value != valueForm {
+			return mapperConfig, fmt.Errorf("parameters mix lists and structures or pointers and values")
+		}
+		path, err := resolver.typePath(typeExpr)
+		if err != nil {
+			return mapperConfig, err
+		}
+		names := param.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{ast.NewIdent("")}
+		}
+		for _, name := range names {
+			alias := name.Name
+			if len(alias) == 0 || alias == "_" {
+				alias = "src"
+				if n := len(mapperConfig.Sources); n != 0 {
+					alias += strconv.Itoa(n)
+				}
+			}
+			mapperConfig.Sources = append(mapperConfig.Sources, sourceConfig{Alias: alias, Path: path})
+		}
+	}
+	if len(mapperConfig.Sources) == 0 {
+		return mapperConfig, fmt.Errorf("no parameter")
+	}
+	if valueForm {
+		mapperConfig.Input = signatureValue
+	}
+	mapperConfig.listMethod = listForm
+
+	var results []ast.Expr
+	if funcType.Results != nil {
+		for _, result := range funcType.Results.List {
+			results = append(results, result.Type)
+			for i := 1; i < len(result.Names); i++ {
+				results = append(results, result.Type)
+			}
+		}
+	}
+	if len(results) == 2 {
+		if ident, ok := results[1].(*ast.Ident); !ok || ident.Name != "error" {
+			return mapperConfig, fmt.Errorf("second result must be an error")
+		}
+		mapperConfig.Errors = true
+	} else if len(results) != 1 {
+		return mapperConfig, fmt.Errorf("one result and an optional error expected")
+	}
+	list, value, typeExpr := methodTypeForm(results[0])
+	if list != listForm {
+		return mapperConfig, fmt.Errorf("parameters and result mix lists and structures")
+	}
+	if value {
+		mapperConfig.Output = signatureValue
+	}
+	path, err := resolver.typePath(typeExpr)
+	if err != nil {
+		return mapperConfig, err
+	}
+	mapperConfig.Destination = sourceConfig{Alias: "dst", Path: path}
+	return mapperConfig, nil
+}
+
+// methodTypeForm strips the list and the pointer off the method parameter
+// or result type.
+func methodTypeForm(typeExpr ast.Expr) (list bool, value bool, _ ast.Expr) {
+	if arrayType, ok := typeExpr.(*ast.ArrayType); ok && arrayType.Len == nil {
+		list, typeExpr = true, arrayType.Elt
+	}
+	if starExpr, ok := typeExpr.(*ast.StarExpr); ok {
+		return list, false, starExpr.X
+	}
+	return list, true, typeExpr
+}
+
+// typePathResolver turns the types of a Go file into paths of the config,
+// "dir/file.Type" relative to the config directory.
+type typePathResolver struct {
+	dir     string
+	fileDir string
+	imports map[string]string
+}
+
+func (r typePathResolver) typePath(typeExpr ast.Expr) (string, error) {
+	switch t := typeExpr.(type) {
+	case *ast.Ident:
+		if _, ok := predeclaredTypes[t.Name]; ok {
+			return t.Name, nil
+		}
+		return r.declarationPath(r.fileDir, t.Name)
+	case *ast.SelectorExpr:
+		packageIdent, ok := t.X.(*ast.Ident)
+		if !ok {
+			break
+		}
+		importPath, exist := r.imports[packageIdent.Name]
+		if !exist {
+			return "", fmt.Errorf("package %s not imported", packageIdent.Name)
+		}
+		packageDir, err := resolveImportDir(r.dir, importPath)
+		if err != nil {
+			return "", err
+		}
+		return r.declarationPath(packageDir, t.Sel.Name)
+	case *ast.IndexExpr:
+		return r.instancePath(t.X, []ast.Expr{t.Index})
+	case *ast.IndexListExpr:
+		return r.instancePath(t.X, t.Indices)
+	}
+	return "", fmt.Errorf("type %s isn't supported", typeStrValue(typeExpr))
+}
+
+func (r typePathResolver) instancePath(typeExpr ast.Expr, typeArgs []ast.Expr) (string, error) {
+	path, err := r.typePath(typeExpr)
+	if err != nil {
+		return "", err
+	}
+	typeArgPaths := make([]string, 0, len(typeArgs))
+	for _, typeArg := range typeArgs {
+		typeArgPath, err := r.typePath(typeArg)
+		if err != nil {
+			return "", err
+		}
+		typeArgPaths = append(typeArgPaths, typeArgPath)
+	}
+	return fmt.Sprintf("%s[%s]", path, strings.Join(typeArgPaths, ", ")), nil
+}
+
+// declarationPath finds the file of the package directory declaring the type.
+func (r typePathResolver) declarationPath(packageDir, typeName string) (string, error) {
+	fileNames, err := filepath.Glob(filepath.Join(packageDir, "*.go"))
+	if err != nil {
+		return "", err
+	}
+	for _, fileName := range fileNames {
+		if strings.HasSuffix(fileName, "_test.go") {
+			continue
+		}
+		fileAST, err := parser.ParseFile(token.NewFileSet(), fileName, nil, 0)
+		if err != nil {
+			continue
+		}
+		if o, exist := fileAST.Scope.Objects[typeName]; !exist || o.Kind != ast.Typ {
+			continue
+		}
+		return configPath(r.dir, fileName, typeName)
+	}
+	return "", fmt.Errorf("type %s not found in %s", typeName, packageDir)
+}
+
+// configPath returns the path of the type declared in the file relative to
+// the config directory.
+func configPath(dir, fileName, typeName string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	absFileName, err := filepath.Abs(fileName)
+	if err != nil {
+		return "", err
+	}
+	relFileName, err := filepath.Rel(absDir, absFileName)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s.%s", strings.TrimSuffix(filepath.ToSlash(relFileName), ".go"), typeName), nil
+}
+
+// fileImports maps the package names used in the file to the import paths.
+func fileImports(fileAST *ast.File) map[string]string {
+	imports := make(map[string]string, len(fileAST.Imports))
+	for _, importSpec := range fileAST.Imports {
+		importPath, err := strconv.Unquote(importSpec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := importPath[strings.LastIndex(importPath, "/")+1:]
+		if versioned := strings.TrimSuffix(importPath, "/"+name); isMajorVersion(name) && versioned != importPath {
+			name = versioned[strings.LastIndex(versioned, "/")+1:]
+		}
+		if importSpec.Name != nil {
+			name = importSpec.Name.Name
+		}
+		imports[name] = importPath
+	}
+	return imports
+}
+
+// isMajorVersion reports whether the last element of an import path is the
+// major version suffix of a module, e.g. v5, the package being named after
+// the element before it.
+func isMajorVersion(name string) bool {
+	if len(name) < 2 || name[0] != 'v' {
+		return false
+	}
+	_, err := strconv.Atoi(name[1:])
+	return err == nil
+}
+
+// resolveImportDir finds the directory of the imported package in GOPATH or
+// in the module enclosing the config directory.
+func resolveImportDir(dir, importPath string) (string, error) {
+	if packageDir := filepath.Join(os.Getenv("GOPATH"), "src", importPath); isDir(packageDir) {
+		return packageDir, nil
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for moduleDir := absDir; ; moduleDir = filepath.Dir(moduleDir) {
+		if data, err := ioutil.ReadFile(filepath.Join(moduleDir, "go.mod")); err == nil {
+			modulePath := goModulePath(data)
+			if importPath == modulePath || strings.HasPrefix(importPath, modulePath+"/") {
+				return filepath.Join(moduleDir, strings.TrimPrefix(importPath, modulePath)), nil
+			}
+			break
+		}
+		if moduleDir == filepath.Dir(moduleDir) {
+			break
+		}
+	}
+	return "", fmt.Errorf("package %s not found", importPath)
+}
+
+// goModulePath returns the module path declared by go.mod.
+func goModulePath(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], "\"")
+		}
+	}
+	return ""
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func lowerFirst(s string) string {
+	for i, r := range s {
+		return string(unicode.ToLower(r)) + s[i+len(string(r)):]
+	}
+	return s
+}
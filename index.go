@@ -0,0 +1,92 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import "fmt"
+
+const (
+	duplicatesLast  = "last"
+	duplicatesFirst = "first"
+	duplicatesError = "error"
+)
+
+// indexConfig configures the mapper building a map of destinations keyed by
+// an expression of the source out of a list of sources.
+type indexConfig struct {
+	// Key is the field or the expression of the source alias the
+	// destinations are keyed by, e.g. ID or user.ID.
+	Key string `yaml:"key"`
+	// KeyType is the type of the key, required when it can't be told from
+	// a source field.
+	KeyType string `yaml:"key_type"`
+	// Duplicates decides which element is kept when several share a key:
+	// last (default), first or error.
+	Duplicates string `yaml:"duplicates"`
+}
+
+// indexParams describes the index mapper of a single source mapper.
+type indexParams struct {
+	Key        string
+	KeyType    string
+	Duplicates string
+}
+
+func (mc mapperConfig) MapMapperName() string {
+	return mc.funcName("MapMapper")
+}
+
+func (mc mapperConfig) IndexMapperName() string {
+	return mc.funcName("IndexMapper")
+}
+
+// IndexReturnsError reports whether the generated index mapper returns an
+// error.
+func (mc mapperConfig) IndexReturnsError() bool {
+	return mc.ReturnsError() || (mc.Index != nil && mc.Index.Duplicates == duplicatesError)
+}
+
+// mapOutputParams validates the map and the index mappers of the mapper,
+// both convert a single source.
+func mapOutputParams(mapperConfig mapperConfig, srcList []src) (*indexParams, error) {
+	if !mapperConfig.MapMapper && mapperConfig.Index == nil {
+		return nil, nil
+	}
+	if len(srcList) != 1 {
+		return nil, fmt.Errorf("mapper %s: map and index mappers require a single source", mapperConfig.MapperName())
+	}
+	if mapperConfig.Index == nil {
+		return nil, nil
+	}
+
+	duplicates := mapperConfig.Index.Duplicates
+	switch duplicates {
+	case "":
+		duplicates = duplicatesLast
+	case duplicatesLast, duplicatesFirst, duplicatesError:
+	default:
+		return nil, fmt.Errorf("mapper %s: unknown duplicates \"%s\"", mapperConfig.MapperName(), duplicates)
+	}
+	srcStruct := srcList[0]
+	keyExpr := mapperConfig.Index.Key
+	if len(keyExpr) == 0 {
+		return nil, fmt.Errorf("mapper %s: index requires key", mapperConfig.MapperName())
+	}
+	if searchField(srcStruct.Fields, keyExpr) != nil {
+		keyExpr = fmt.Sprintf("%s.%s", srcStruct.Alias, keyExpr)
+	}
+	keyType, err := joinKeyType(keyExpr, srcStruct, mapperConfig.Index.KeyType)
+	if err != nil {
+		return nil, fmt.Errorf("mapper %s: %w", mapperConfig.MapperName(), err)
+	}
+	key, err := replaceAlias(keyExpr, srcStruct.Alias, "v")
+	if err != nil {
+		return nil, fmt.Errorf("mapper %s: %w", mapperConfig.MapperName(), err)
+	}
+	return &indexParams{
+		Key:        key,
+		KeyType:    keyType,
+		Duplicates: duplicates,
+	}, nil
+}
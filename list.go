@@ -0,0 +1,202 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+)
+
+const (
+	listZipShortest = "zip_shortest"
+	listZipLongest  = "zip_longest"
+	listStrict      = "strict"
+	listJoin        = "join"
+)
+
+// listSource describes how the list mapper passes the elements of a source
+// to the element mapper.
+type listSource struct {
+	Alias     string
+	ShortPath string
+	// Item is the element expression passed to the element mapper.
+	Item string
+	// Primary is the source the join list mode iterates, the elements of
+	// the other sources are looked up in an index by Key, an expression of
+	// the indexed element v, with PrimaryKey, the same expression of the
+	// primary element. IndexItem is the indexed element in the form the
+	// element mapper takes, IndexPos tells it's taken by the position j.
+	Primary    bool
+	Key        string
+	KeyType    string
+	PrimaryKey string
+	IndexItem  string
+	IndexPos   bool
+}
+
+// listParams validates the list mode of the mapper and describes how each
+// source feeds the element mapper.
+func listParams(mapperConfig mapperConfig, srcList []src, options castOptions) (string, []listSource, error) {
+	list := mapperConfig.List
+	switch list {
+	case "":
+		list = listZipShortest
+	case listZipShortest, listZipLongest, listStrict, listJoin:
+	default:
+		return "", nil, fmt.Errorf("mapper %s: unknown list \"%s\"", mapperConfig.MapperName(), list)
+	}
+	if len(srcList) == 0 {
+		return "", nil, fmt.Errorf("mapper %s: no source", mapperConfig.MapperName())
+	}
+
+	listValue, value := mapperConfig.ListInputValue(), mapperConfig.InputValue()
+	listSources := make([]listSource, 0, len(srcList))
+	for i, srcStruct := range srcList {
+		listSource := listSource{
+			Alias:     srcStruct.Alias,
+			ShortPath: srcStruct.ShortPath,
+			Item:      fmt.Sprintf("%s[i]", srcStruct.Alias),
+		}
+		switch list {
+		case listZipLongest:
+			listSource.Item = fmt.Sprintf("%sItem", srcStruct.Alias)
+		case listJoin:
+			listSource.Primary = i == 0
+			if !listSource.Primary {
+				// The indexed elements are in the form the element mapper
+				// takes already, nil pointers being skipped.
+				listSource.Item = fmt.Sprintf("%sItem", srcStruct.Alias)
+				listSource.IndexItem, listSource.IndexPos = indexedElemStr(srcStruct.Alias, listValue, value)
+				listSources = append(listSources, listSource)
+				continue
+			}
+		}
+		listSource.Item = formStr(listSource.Item, srcStruct.ShortPath, listValue, value, options)
+		listSources = append(listSources, listSource)
+	}
+	if list == listJoin {
+		if err := joinKeys(mapperConfig, srcList, listSources); err != nil {
+			return "", nil, err
+		}
+	}
+	return list, listSources, nil
+}
+
+// indexedElemStr adapts the element v at the position j of the list to the
+// form the element mapper takes, pos tells the position is used. Nil
+// pointers are skipped before.
+func indexedElemStr(alias string, listValue, value bool) (elem string, pos bool) {
+	switch {
+	case listValue && !value:
+		return fmt.Sprintf("&%s[j]", alias), true
+	case !listValue && value:
+		return "*v", false
+	}
+	return "v", false
+}
+
+// joinKeyExpr returns the join key expression of the source at index i,
+// its own or the join key field of the mapper selected from its alias.
+func (mc mapperConfig) joinKeyExpr(i int, alias string) string {
+	if i < len(mc.Sources) && len(mc.Sources[i].JoinKey) != 0 {
+		return mc.Sources[i].JoinKey
+	}
+	if len(mc.JoinKey) != 0 {
+		return fmt.Sprintf("%s.%s", alias, mc.JoinKey)
+	}
+	return ""
+}
+
+// joinKeys fills the keys the join list mode matches the elements on, the
+// join key expressions of the sources checked against the primary one.
+func joinKeys(mapperConfig mapperConfig, srcList []src, listSources []listSource) error {
+	var primaryKey, primaryKeyType string
+	for i, srcStruct := range srcList {
+		keyExpr := mapperConfig.joinKeyExpr(i, srcStruct.Alias)
+		if len(keyExpr) == 0 {
+			return fmt.Errorf("mapper %s: join list requires join_key for source %s", mapperConfig.MapperName(), srcStruct.Alias)
+		}
+		keyType, err := joinKeyType(keyExpr, srcStruct, mapperConfig.JoinKeyType)
+		if err != nil {
+			return fmt.Errorf("mapper %s: %w", mapperConfig.MapperName(), err)
+		}
+		if i == 0 {
+			primaryKey, err = replaceAlias(keyExpr, srcStruct.Alias, fmt.Sprintf("%s[i]", srcStruct.Alias))
+			if err != nil {
+				return fmt.Errorf("mapper %s: %w", mapperConfig.MapperName(), err)
+			}
+			primaryKeyType = keyType
+			continue
+		}
+		if keyType != primaryKeyType {
+			return fmt.Errorf("mapper %s: join key %s %s of source %s doesn't match %s", mapperConfig.MapperName(), keyExpr, keyType, srcStruct.Alias, primaryKeyType)
+		}
+		key, err := replaceAlias(keyExpr, srcStruct.Alias, "v")
+		if err != nil {
+			return fmt.Errorf("mapper %s: %w", mapperConfig.MapperName(), err)
+		}
+		listSources[i].Key = key
+		listSources[i].KeyType = keyType
+		listSources[i].PrimaryKey = primaryKey
+	}
+	return nil
+}
+
+// joinKeyType returns the type of the join key expression, taken from the
+// source field it selects or the configured key type.
+func joinKeyType(keyExpr string, srcStruct src, keyType string) (string, error) {
+	if len(keyType) != 0 {
+		return keyType, nil
+	}
+	expr, err := parser.ParseExpr(keyExpr)
+	if err != nil {
+		return "", fmt.Errorf("join key %s: %w", keyExpr, err)
+	}
+	if selector, ok := expr.(*ast.SelectorExpr); ok {
+		if ident, ok := selector.X.(*ast.Ident); ok && ident.Name == srcStruct.Alias {
+			if keyField := searchField(srcStruct.Fields, selector.Sel.Name); keyField != nil && !keyField.Ptr {
+				return keyField.TypeStr, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("join key %s is not a field of source %s, join_key_type is required", keyExpr, srcStruct.Alias)
+}
+
+// replaceAlias rewrites the expression of the source alias into an
+// expression of the element it's evaluated on.
+func replaceAlias(keyExpr, alias, element string) (string, error) {
+	expr, err := parser.ParseExpr(keyExpr)
+	if err != nil {
+		return "", fmt.Errorf("join key %s: %w", keyExpr, err)
+	}
+	var found bool
+	var rewrite func(node ast.Node) bool
+	rewrite = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.SelectorExpr:
+			// Only the operand of a selector can be the alias.
+			ast.Inspect(node.X, rewrite)
+			return false
+		case *ast.Ident:
+			if node.Name == alias {
+				node.Name, found = element, true
+			}
+		}
+		return true
+	}
+	ast.Inspect(expr, rewrite)
+	if !found {
+		return "", fmt.Errorf("join key %s doesn't use source %s", keyExpr, alias)
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, token.NewFileSet(), expr); err != nil {
+		return "", fmt.Errorf("join key %s: %w", keyExpr, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,362 @@
+// Package mapping holds the generic helpers called by the code mapstruct
+// generates. Keeping them in one importable package lets several generated
+// files share the package they are generated into. The helpers are generic,
+// so the module of the generated code needs Go 1.18 or later.
+package mapping
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// Number is the set of types numeric fields are converted between.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Ptr returns a pointer to a copy of v.
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// Deref returns the value p points to or the zero value when p is nil.
+func Deref[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
+
+// ConvertSlice converts every element of src into D.
+func ConvertSlice[S, D Number](src []S) []D {
+	if src == nil {
+		return nil
+	}
+	dst := make([]D, len(src))
+	for i := range src {
+		dst[i] = D(src[i])
+	}
+	return dst
+}
+
+// ConvertPtr converts the value p points to into D, keeping nil as nil.
+func ConvertPtr[S, D Number](p *S) *D {
+	if p == nil {
+		return nil
+	}
+	v := D(*p)
+	return &v
+}
+
+// PtrSlice returns a slice of pointers to copies of the src elements.
+func PtrSlice[T any](src []T) []*T {
+	if src == nil {
+		return nil
+	}
+	dst := make([]*T, len(src))
+	for i := range src {
+		v := src[i]
+		dst[i] = &v
+	}
+	return dst
+}
+
+// DerefSlice returns a slice of the values the src elements point to, nil
+// elements become zero values.
+func DerefSlice[T any](src []*T) []T {
+	if src == nil {
+		return nil
+	}
+	dst := make([]T, len(src))
+	for i := range src {
+		dst[i] = Deref(src[i])
+	}
+	return dst
+}
+
+// MapSlice maps every element of src with f.
+func MapSlice[S, D any](src []S, f func(S) D) []D {
+	if src == nil {
+		return nil
+	}
+	dst := make([]D, len(src))
+	for i := range src {
+		dst[i] = f(src[i])
+	}
+	return dst
+}
+
+// TryMapSlice maps every element of src with f and stops at the first error.
+func TryMapSlice[S, D any](src []S, f func(S) (D, error)) ([]D, error) {
+	if src == nil {
+		return nil, nil
+	}
+	dst := make([]D, len(src))
+	for i := range src {
+		v, err := f(src[i])
+		if err != nil {
+			return nil, fmt.Errorf("index %d: %w", i, err)
+		}
+		dst[i] = v
+	}
+	return dst, nil
+}
+
+// MapMap maps every value of src with f, keeping the keys.
+func MapMap[K comparable, S, D any](src map[K]S, f func(S) D) map[K]D {
+	if src == nil {
+		return nil
+	}
+	dst := make(map[K]D, len(src))
+	for k, v := range src {
+		dst[k] = f(v)
+	}
+	return dst
+}
+
+// ClonePtr returns a pointer to a copy of the value p points to, keeping nil
+// as nil.
+func ClonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+// CloneSlice returns a copy of src, keeping nil as nil.
+func CloneSlice[S ~[]E, E any](src S) S {
+	if src == nil {
+		return nil
+	}
+	dst := make(S, len(src))
+	copy(dst, src)
+	return dst
+}
+
+// CloneMap returns a copy of src, keeping nil as nil.
+func CloneMap[M ~map[K]V, K comparable, V any](src M) M {
+	if src == nil {
+		return nil
+	}
+	dst := make(M, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// Graph tracks the walk of cycle-safe mappers through a source graph: the
+// destinations already mapped from source pointers, so shared and cyclic
+// references map to the same destination, and the depth of the walk.
+type Graph struct {
+	maxDepth int
+	depth    int
+	visited  map[graphKey]any
+}
+
+type graphKey struct {
+	mapper string
+	src    any
+}
+
+// NewGraph returns a Graph limiting the walk to maxDepth nested mappers, or
+// not limiting it when maxDepth is 0.
+func NewGraph(maxDepth int) *Graph {
+	return &Graph{maxDepth: maxDepth}
+}
+
+// Enter reports whether the walk may map one more level, callers entering
+// it call Leave once they are done.
+func (g *Graph) Enter() bool {
+	if g.maxDepth > 0 && g.depth >= g.maxDepth {
+		return false
+	}
+	g.depth++
+	return true
+}
+
+// Leave ends the level started by Enter.
+func (g *Graph) Leave() {
+	g.depth--
+}
+
+// Lookup returns the destination the mapper mapped src into.
+func (g *Graph) Lookup(mapper string, src any) (any, bool) {
+	dst, ok := g.visited[graphKey{mapper: mapper, src: src}]
+	return dst, ok
+}
+
+// Store remembers the destination the mapper maps src into.
+func (g *Graph) Store(mapper string, src, dst any) {
+	if g.visited == nil {
+		g.visited = make(map[graphKey]any)
+	}
+	g.visited[graphKey{mapper: mapper, src: src}] = dst
+}
+
+// Validator is implemented by the destinations validating themselves.
+type Validator interface {
+	Validate() error
+}
+
+// Validate calls the Validate method of v when it implements Validator.
+func Validate(v any) error {
+	if validator, ok := v.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// FromMap returns the value of the key converted into T, the zero value
+// when the key is missing or nil. Numbers convert into the numeric types
+// holding them without loss and lists into slices of any element type, so
+// maps decoded from JSON fill typed fields, other mismatches are errors.
+func FromMap[T any](m map[string]any, key string) (T, error) {
+	var v T
+	value := m[key]
+	if value == nil {
+		return v, nil
+	}
+	if err := assign(reflect.ValueOf(&v).Elem(), reflect.ValueOf(value)); err != nil {
+		return v, fmt.Errorf("key %s: %w", key, err)
+	}
+	return v, nil
+}
+
+// TimeFromMap returns the time of the key, held as a time.Time or as a
+// string formatted with the layout.
+func TimeFromMap(m map[string]any, key, layout string) (time.Time, error) {
+	switch value := m[key].(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		return value, nil
+	case string:
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			return t, fmt.Errorf("key %s: %w", key, err)
+		}
+		return t, nil
+	default:
+		return time.Time{}, fmt.Errorf("key %s: %T isn't a time", key, value)
+	}
+}
+
+// NestedFromMap maps the nested map of the key with the mapper.
+func NestedFromMap[T any](m map[string]any, key string, mapper func(map[string]any) (T, error)) (T, error) {
+	nested, err := FromMap[map[string]any](m, key)
+	if err != nil {
+		var v T
+		return v, err
+	}
+	v, err := mapper(nested)
+	if err != nil {
+		return v, fmt.Errorf("key %s: %w", key, err)
+	}
+	return v, nil
+}
+
+// NestedSliceFromMap maps the nested maps listed by the key with the
+// mapper.
+func NestedSliceFromMap[T any](m map[string]any, key string, mapper func(map[string]any) (T, error)) ([]T, error) {
+	list, err := FromMap[[]map[string]any](m, key)
+	if err != nil || list == nil {
+		return nil, err
+	}
+	dst := make([]T, 0, len(list))
+	for i, nested := range list {
+		v, err := mapper(nested)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: index %d: %w", key, i, err)
+		}
+		dst = append(dst, v)
+	}
+	return dst, nil
+}
+
+// assign sets dst to src, converting numbers without loss, named types of
+// the same kind, lists element by element and pointing to the value when
+// dst is a pointer.
+func assign(dst, src reflect.Value) error {
+	if src.Kind() == reflect.Interface {
+		if src.IsNil() {
+			dst.Set(reflect.Zero(dst.Type()))
+			return nil
+		}
+		src = src.Elem()
+	}
+	switch {
+	case src.Type().AssignableTo(dst.Type()):
+		dst.Set(src)
+	case isNumber(src) && isNumber(dst):
+		converted := src.Convert(dst.Type())
+		lossy := converted.Convert(src.Type()).Interface() != src.Interface()
+		if src.CanFloat() && dst.CanFloat() {
+			lossy = math.IsInf(converted.Float(), 0) && !math.IsInf(src.Float(), 0)
+		}
+		if lossy || isNegative(src) != isNegative(converted) {
+			return fmt.Errorf("%v can't be converted to %s without loss", src, dst.Type())
+		}
+		dst.Set(converted)
+	case src.Kind() == dst.Kind() && (src.Kind() == reflect.String || src.Kind() == reflect.Bool):
+		dst.Set(src.Convert(dst.Type()))
+	case src.Kind() == reflect.Slice && dst.Kind() == reflect.Slice:
+		list := reflect.MakeSlice(dst.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			if err := assign(list.Index(i), src.Index(i)); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		dst.Set(list)
+	case dst.Kind() == reflect.Pointer:
+		p := reflect.New(dst.Type().Elem())
+		if err := assign(p.Elem(), src); err != nil {
+			return err
+		}
+		dst.Set(p)
+	default:
+		return fmt.Errorf("%s isn't %s", src.Type(), dst.Type())
+	}
+	return nil
+}
+
+func isNumber(v reflect.Value) bool {
+	return v.CanInt() || v.CanUint() || v.CanFloat()
+}
+
+func isNegative(v reflect.Value) bool {
+	switch {
+	case v.CanInt():
+		return v.Int() < 0
+	case v.CanFloat():
+		return v.Float() < 0
+	}
+	return false
+}
+
+// CheckedConvert converts v into D and reports an error when the value
+// overflows D or is truncated by the conversion.
+func CheckedConvert[S, D Number](v S) (D, error) {
+	d := D(v)
+	if isFloat[S]() && isFloat[D]() {
+		if math.IsInf(float64(d), 0) && !math.IsInf(float64(v), 0) {
+			return d, fmt.Errorf("%v can't be converted to %T without loss", v, d)
+		}
+		return d, nil
+	}
+	if S(d) != v || (v < 0) != (d < 0) {
+		return d, fmt.Errorf("%v can't be converted to %T without loss", v, d)
+	}
+	return d, nil
+}
+
+func isFloat[T Number]() bool {
+	half := 0.5
+	return T(half) != 0
+}
@@ -0,0 +1,204 @@
+package mapping
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckedConvert(t *testing.T) {
+	tests := []struct {
+		name    string
+		convert func() (interface{}, error)
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name:    "Fits",
+			convert: func() (interface{}, error) { return CheckedConvert[int64, int8](-100) },
+			want:    int8(-100),
+		},
+		{
+			name:    "Overflow",
+			convert: func() (interface{}, error) { return CheckedConvert[int64, int8](300) },
+			wantErr: true,
+		},
+		{
+			name:    "Negative to unsigned",
+			convert: func() (interface{}, error) { return CheckedConvert[int, uint16](-1) },
+			wantErr: true,
+		},
+		{
+			name:    "Unsigned to signed overflow",
+			convert: func() (interface{}, error) { return CheckedConvert[uint64, int64](1 << 63) },
+			wantErr: true,
+		},
+		{
+			name:    "Float truncation",
+			convert: func() (interface{}, error) { return CheckedConvert[float64, int](3.5) },
+			wantErr: true,
+		},
+		{
+			name:    "Float precision",
+			convert: func() (interface{}, error) { return CheckedConvert[float64, float32](0.1) },
+			want:    float32(0.1),
+		},
+		{
+			name:    "Float overflow",
+			convert: func() (interface{}, error) { return CheckedConvert[float64, float32](1e300) },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.convert()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckedConvert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckedConvert() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapSlice(t *testing.T) {
+	if got := MapSlice[int, string](nil, func(int) string { return "" }); got != nil {
+		t.Errorf("MapSlice() = %v, want nil", got)
+	}
+	got := MapSlice([]int{1, 2}, func(v int) *int { return Ptr(v * 2) })
+	if want := []int{2, 4}; !reflect.DeepEqual(DerefSlice(got), want) {
+		t.Errorf("MapSlice() = %v, want %v", DerefSlice(got), want)
+	}
+}
+
+func TestClone(t *testing.T) {
+	type tags []string
+	if got := CloneSlice(tags(nil)); got != nil {
+		t.Errorf("CloneSlice() = %v, want nil", got)
+	}
+	src := tags{"a", "b"}
+	dst := CloneSlice(src)
+	dst[0] = "c"
+	if src[0] != "a" {
+		t.Errorf("CloneSlice() shares memory with %v", src)
+	}
+	m := map[string]int{"a": 1}
+	if cm := CloneMap(m); !reflect.DeepEqual(cm, m) {
+		t.Errorf("CloneMap() = %v, want %v", cm, m)
+	} else if cm["a"] = 2; m["a"] != 1 {
+		t.Errorf("CloneMap() shares memory with %v", m)
+	}
+	v := 1
+	if p := ClonePtr(&v); p == &v || *p != v {
+		t.Errorf("ClonePtr() = %v, want a copy of %v", p, &v)
+	}
+	if p := ClonePtr[int](nil); p != nil {
+		t.Errorf("ClonePtr() = %v, want nil", p)
+	}
+}
+
+func TestGraph(t *testing.T) {
+	g := NewGraph(2)
+	if !g.Enter() || !g.Enter() || g.Enter() {
+		t.Fatal("Enter() doesn't stop at the max depth")
+	}
+	g.Leave()
+	if !g.Enter() {
+		t.Fatal("Enter() doesn't resume after Leave()")
+	}
+	src, dst := new(int), new(string)
+	if _, ok := g.Lookup("M", src); ok {
+		t.Fatal("Lookup() found an unknown source")
+	}
+	g.Store("M", src, dst)
+	if got, ok := g.Lookup("M", src); !ok || got != dst {
+		t.Errorf("Lookup() = %v, %v, want %v", got, ok, dst)
+	}
+	if _, ok := g.Lookup("N", src); ok {
+		t.Error("Lookup() found the source of another mapper")
+	}
+}
+
+type validated struct{ ok bool }
+
+func (v *validated) Validate() error {
+	if !v.ok {
+		return errors.New("invalid")
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(&validated{ok: true}); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if err := Validate(&validated{}); err == nil {
+		t.Error("Validate() doesn't call the Validate method")
+	}
+	if err := Validate(new(int)); err != nil {
+		t.Errorf("Validate() error = %v for a value without Validate method", err)
+	}
+}
+
+type level string
+
+func TestFromMap(t *testing.T) {
+	m := map[string]any{"id": 42.0, "ratio": 0.5, "level": "high", "tags": []any{"a", "b"}, "ids": []any{1.0, 2.0}, "nil": nil}
+	if v, err := FromMap[int64](m, "id"); err != nil || v != 42 {
+		t.Errorf("FromMap() = %v, %v, want 42", v, err)
+	}
+	if v, err := FromMap[*uint8](m, "id"); err != nil || v == nil || *v != 42 {
+		t.Errorf("FromMap() = %v, %v, want pointer to 42", v, err)
+	}
+	if v, err := FromMap[level](m, "level"); err != nil || v != "high" {
+		t.Errorf("FromMap() = %v, %v, want high", v, err)
+	}
+	if v, err := FromMap[[]string](m, "tags"); err != nil || !reflect.DeepEqual(v, []string{"a", "b"}) {
+		t.Errorf("FromMap() = %v, %v, want [a b]", v, err)
+	}
+	if v, err := FromMap[[]int](m, "ids"); err != nil || !reflect.DeepEqual(v, []int{1, 2}) {
+		t.Errorf("FromMap() = %v, %v, want [1 2]", v, err)
+	}
+	if v, err := FromMap[*int](m, "missing"); err != nil || v != nil {
+		t.Errorf("FromMap() = %v, %v for a missing key", v, err)
+	}
+	if v, err := FromMap[string](m, "nil"); err != nil || v != "" {
+		t.Errorf("FromMap() = %v, %v for a nil value", v, err)
+	}
+	for _, key := range []string{"ratio", "level"} {
+		if _, err := FromMap[int](m, key); err == nil {
+			t.Errorf("FromMap() converts %v into an int", m[key])
+		}
+	}
+	if _, err := FromMap[uint](map[string]any{"n": -1}, "n"); err == nil {
+		t.Error("FromMap() converts -1 into an uint")
+	}
+}
+
+func TestTimeFromMap(t *testing.T) {
+	want := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for _, value := range []any{want, "2024-05-01T10:00:00Z"} {
+		if v, err := TimeFromMap(map[string]any{"at": value}, "at", time.RFC3339); err != nil || !v.Equal(want) {
+			t.Errorf("TimeFromMap() = %v, %v, want %v", v, err, want)
+		}
+	}
+	if _, err := TimeFromMap(map[string]any{"at": 1}, "at", time.RFC3339); err == nil {
+		t.Error("TimeFromMap() accepts an int")
+	}
+}
+
+func TestNestedSliceFromMap(t *testing.T) {
+	mapper := func(m map[string]any) (string, error) {
+		return FromMap[string](m, "name")
+	}
+	m := map[string]any{"items": []any{map[string]any{"name": "a"}, map[string]any{"name": 1}}}
+	if _, err := NestedSliceFromMap(m, "items", mapper); err == nil || err.Error() != "key items: index 1: key name: int isn't string" {
+		t.Errorf("NestedSliceFromMap() error = %v", err)
+	}
+	m["items"] = m["items"].([]any)[:1]
+	if v, err := NestedSliceFromMap(m, "items", mapper); err != nil || !reflect.DeepEqual(v, []string{"a"}) {
+		t.Errorf("NestedSliceFromMap() = %v, %v, want [a]", v, err)
+	}
+}
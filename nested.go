@@ -0,0 +1,190 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"fmt"
+	pathUtil "path"
+	"strings"
+)
+
+// nestedMapper is a configured single source mapper other mappers call to
+// convert fields of its source type into its destination type.
+type nestedMapper struct {
+	MapperFuncName     string
+	ListMapperFuncName string
+	ReturnsError       bool
+	// InputValue and OutputValue tell the mapper takes its source and
+	// returns its destination by value, ListInputValue and ListOutputValue
+	// tell the same of its list mapper elements.
+	InputValue      bool
+	OutputValue     bool
+	ListInputValue  bool
+	ListOutputValue bool
+	// GraphFuncName names the variant of cycle-aware mappers taking the
+	// graph of the caller, Graph tells MapperFuncName is that variant.
+	GraphFuncName string
+	Graph         bool
+	// Context tells the mapper takes the context of the caller first,
+	// Params are the extra parameters it takes after the source.
+	Context bool
+	Params  []paramConfig
+}
+
+// collectNestedMappers indexes single source mappers by their source and
+// destination types.
+func collectNestedMappers(mappersConfig *config) (map[[2]string]nestedMapper, error) {
+	nestedMappers := make(map[[2]string]nestedMapper, len(mappersConfig.Mappers))
+	for _, mapperConfig := range mappersConfig.Mappers {
+		if len(mapperConfig.Sources) != 1 {
+			continue
+		}
+		dstMeta, err := parseStructure(pathUtil.Dir(mappersConfig.path), mapperConfig.Destination.Path)
+		if err != nil {
+			return nil, err
+		}
+		srcMeta, err := parseStructure(pathUtil.Dir(mappersConfig.path), mapperConfig.Sources[0].Path)
+		if err != nil {
+			return nil, err
+		}
+		nestedMappers[[2]string{shortPath(srcMeta), shortPath(dstMeta)}] = nestedMapper{
+			MapperFuncName:     mapperConfig.MapperName(),
+			ListMapperFuncName: mapperConfig.ListMapperName(),
+			ReturnsError:       mapperConfig.ReturnsError(),
+			InputValue:         mapperConfig.InputValue(),
+			OutputValue:        mapperConfig.OutputValue(),
+			ListInputValue:     mapperConfig.ListInputValue(),
+			ListOutputValue:    mapperConfig.ListOutputValue(),
+			GraphFuncName:      mapperConfig.GraphFuncName(),
+			Context:            mapperConfig.Context,
+			Params:             mapperConfig.Params,
+		}
+	}
+	return nestedMappers, nil
+}
+
+// castNestedField converts structure fields, slices and maps of structures by
+// calling the mapper configured for their types. checked tells the
+// expression also returns an error, castAddr tells the value must be
+// assigned by its address.
+func castNestedField(srcAlias string, srcField, dstField field, options castOptions) (castStr string, checked bool, castAddr bool, ok bool) {
+	srcRow := fmt.Sprintf("%s.%s", srcAlias, srcField.Name)
+	srcType, dstType := srcField.TypeStr, dstField.TypeStr
+	switch {
+	case strings.HasPrefix(srcType, "[]") && strings.HasPrefix(dstType, "[]"):
+		srcElem, dstElem := strings.TrimPrefix(srcType, "[]"), strings.TrimPrefix(dstType, "[]")
+		mapper, exist := options.nestedMapper(srcElem, dstElem)
+		if !exist || (mapper.ReturnsError && !options.returnsError) {
+			return srcRow, false, false, false
+		}
+		if len(mapper.ListMapperFuncName) != 0 && strings.HasPrefix(srcElem, "*") != mapper.ListInputValue && strings.HasPrefix(dstElem, "*") != mapper.ListOutputValue {
+			return mapper.callStr(mapper.ListMapperFuncName, srcRow), mapper.ReturnsError, false, true
+		}
+		if mapper.Graph && mapper.ReturnsError && strings.HasPrefix(srcElem, "*") && strings.HasPrefix(dstElem, "*") {
+			return fmt.Sprintf("%s.TryMapSlice(%s, func(v %s) (%s, error) { return %s })",
+				useImport("mapping", mappingPackage), srcRow, srcElem, dstElem, mapper.callStr(mapper.MapperFuncName, "v")), true, false, true
+		}
+		if mapper.ReturnsError || options.inlineHelpers {
+			return srcRow, false, false, false
+		}
+		elemCastStr, _, ok := nestedCallStr("v", srcElem, dstElem, mapper, options)
+		if !ok {
+			return srcRow, false, false, false
+		}
+		return mapSliceStr(srcRow, srcElem, dstElem, elemCastStr), false, false, true
+	case strings.HasPrefix(srcType, "map[") && strings.HasPrefix(dstType, "map["):
+		srcKey, srcValue := splitMapType(srcType)
+		dstKey, dstValue := splitMapType(dstType)
+		mapper, exist := options.nestedMapper(srcValue, dstValue)
+		if srcKey != dstKey || !exist || mapper.ReturnsError || options.inlineHelpers {
+			return srcRow, false, false, false
+		}
+		mapperFunc := mapper.MapperFuncName
+		if mapper.Graph || mapper.Context || strings.HasPrefix(srcValue, "*") == mapper.InputValue || strings.HasPrefix(dstValue, "*") == mapper.OutputValue {
+			valueCastStr, _, ok := nestedCallStr("v", srcValue, dstValue, mapper, options)
+			if !ok {
+				return srcRow, false, false, false
+			}
+			mapperFunc = fmt.Sprintf("func(v %s) %s { return %s }", srcValue, dstValue, valueCastStr)
+		}
+		return fmt.Sprintf("%s.MapMap(%s, %s)", useImport("mapping", mappingPackage), srcRow, mapperFunc), false, false, true
+	}
+	mapper, exist := options.nestedMapper(srcType, dstType)
+	if !exist || (mapper.ReturnsError && !options.returnsError) {
+		return srcRow, false, false, false
+	}
+	castStr, castAddr, ok = nestedCallStr(srcRow, srcType, dstType, mapper, options)
+	if !ok {
+		return srcRow, false, false, false
+	}
+	return castStr, mapper.ReturnsError, castAddr, true
+}
+
+// nestedCallStr calls the mapper with the structure or the pointer to it
+// and adapts the result to the expected type. castAddr tells the result of
+// an error returning mapper must be assigned by its address. A nil pointer
+// source can't be mapped by a mapper taking or returning values without
+// turning it into a non nil destination, so those shapes are refused.
+func nestedCallStr(srcRow, srcType, dstType string, mapper nestedMapper, options castOptions) (castStr string, castAddr bool, ok bool) {
+	srcPtr, dstPtr := strings.HasPrefix(srcType, "*"), strings.HasPrefix(dstType, "*")
+	switch {
+	case srcPtr && mapper.InputValue:
+		return srcRow, false, false
+	case !srcPtr && !mapper.InputValue:
+		srcRow = "&" + srcRow
+	}
+	castStr = mapper.callStr(mapper.MapperFuncName, srcRow)
+	switch {
+	case dstPtr != mapper.OutputValue:
+		return castStr, false, true
+	case dstPtr:
+		if srcPtr {
+			return srcRow, false, false
+		}
+		if mapper.ReturnsError {
+			return castStr, true, true
+		}
+		return ptrStr(strings.TrimPrefix(dstType, "*"), castStr, options), false, true
+	case mapper.ReturnsError:
+		return srcRow, false, false
+	case options.inlineHelpers:
+		if srcPtr {
+			return srcRow, false, false
+		}
+		return "*" + castStr, false, true
+	}
+	return fmt.Sprintf("%s.Deref(%s)", useImport("mapping", mappingPackage), castStr), false, true
+}
+
+// callStr calls the function of the mapper with the argument, passing the
+// context, the extra parameters and the graph of the caller when the mapper
+// takes them.
+func (mapper nestedMapper) callStr(funcName, arg string) string {
+	if mapper.Context {
+		arg = contextArg + ", " + arg
+	}
+	arg += paramArgs(mapper.Params)
+	if mapper.Graph {
+		arg += ", graph"
+	}
+	return fmt.Sprintf("%s(%s)", funcName, arg)
+}
+
+// splitMapType splits "map[K]V" into its key and value types.
+func splitMapType(typeStr string) (string, string) {
+	depth := 0
+	for i, r := range typeStr {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return typeStr[len("map["):i], typeStr[i+1:]
+			}
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,295 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// oneofWrapper is the structure protoc-gen-go wraps a oneof case into, e.g.
+// pb.User_Email, Field being its single field.
+type oneofWrapper struct {
+	Type  string
+	Field field
+}
+
+// structOneofs returns the wrappers of the oneof fields of the protobuf
+// message by field name, ordered by field number. The wrappers are the
+// structures implementing the unexported interface of the field.
+func structOneofs(meta *structMeta) map[string][]oneofWrapper {
+	if len(meta.fileLocation) == 0 {
+		return nil
+	}
+	interfaceFields := make(map[string]string)
+	for _, f := range meta.fields {
+		if tag, err := strconv.Unquote(f.tag); err == nil {
+			if _, oneof := reflect.StructTag(tag).Lookup("protobuf_oneof"); oneof {
+				interfaceFields[typeStrValue(f.typeAST)] = f.name
+			}
+		}
+	}
+	if len(interfaceFields) == 0 {
+		return nil
+	}
+	packages, err := parser.ParseDir(token.NewFileSet(), filepath.Dir(meta.fileLocation), func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil
+	}
+	scope := typeScope{packageAlias: getPackageAlias(meta.packagePath), types: meta.types, imports: meta.imports}
+	oneofs := make(map[string][]oneofWrapper, len(interfaceFields))
+	numbers := make(map[string]int)
+	for _, p := range packages {
+		for _, f := range p.Files {
+			for _, decl := range f.Decls {
+				funcDecl, ok := decl.(*ast.FuncDecl)
+				if !ok || funcDecl.Recv == nil || len(funcDecl.Recv.List) != 1 {
+					continue
+				}
+				fieldName, exist := interfaceFields[funcDecl.Name.Name]
+				if !exist {
+					continue
+				}
+				wrapperName := receiverName(funcDecl.Recv.List[0].Type)
+				ts, exist := meta.types[wrapperName]
+				if !exist {
+					continue
+				}
+				structType, ok := ts.Type.(*ast.StructType)
+				if !ok || len(structType.Fields.List) != 1 || len(structType.Fields.List[0].Names) != 1 {
+					continue
+				}
+				wrapperField := structType.Fields.List[0]
+				wrapper := oneofWrapper{
+					Type: qualifiedTypeStr(ts.Name, scope),
+					Field: field{
+						Name:       wrapperField.Names[0].Name,
+						Ptr:        isPtr(wrapperField.Type),
+						TypeStr:    qualifiedTypeStr(wrapperField.Type, scope),
+						Underlying: underlyingTypeStr(wrapperField.Type, scope),
+					},
+				}
+				if wrapperField.Tag != nil {
+					wrapper.Field.Tag = wrapperField.Tag.Value
+				}
+				numbers[wrapper.Type] = protobufNumber(wrapper.Field.Tag)
+				oneofs[fieldName] = append(oneofs[fieldName], wrapper)
+			}
+		}
+	}
+	for _, wrappers := range oneofs {
+		sort.Slice(wrappers, func(i, j int) bool {
+			return numbers[wrappers[i].Type] < numbers[wrappers[j].Type]
+		})
+	}
+	return oneofs
+}
+
+// protobufNumber returns the field number the protobuf tag declares.
+func protobufNumber(tag string) int {
+	tag, err := strconv.Unquote(tag)
+	if err != nil {
+		return 0
+	}
+	options := strings.Split(reflect.StructTag(tag).Get("protobuf"), ",")
+	if len(options) < 2 {
+		return 0
+	}
+	number, _ := strconv.Atoi(options[1])
+	return number
+}
+
+// oneofVariantCases returns the cases of the type switch mapping the oneof
+// field of the source message into the sum type interface of the
+// destination or the sum type of the source into the oneof field of the
+// destination message. The types of the sum are told by the mappers of the
+// wrapped messages, the cases of the messages mapped from or into several
+// types and of the wrapped scalars are left out.
+func oneofVariantCases(srcStruct, dst src, fieldName string, options castOptions) ([]variantCase, bool) {
+	var cases []variantCase
+	if wrappers, exist := srcStruct.Oneofs[fieldName]; exist {
+		for _, wrapper := range wrappers {
+			dstType, mapper, ok := uniqueMapper(options, strings.TrimPrefix(wrapper.Field.TypeStr, "*"), true)
+			if !ok {
+				continue
+			}
+			castStr, castAddr, ok := nestedCallStr("v."+wrapper.Field.Name, wrapper.Field.TypeStr, dstType, mapper, options)
+			if !ok || (mapper.ReturnsError && !options.returnsError) {
+				continue
+			}
+			cases = append(cases, variantCase{Type: "*" + wrapper.Type, CastStr: castStr, Checked: mapper.ReturnsError, CastAddr: castAddr})
+		}
+		return cases, true
+	}
+	if wrappers, exist := dst.Oneofs[fieldName]; exist {
+		for _, wrapper := range wrappers {
+			srcType, mapper, ok := uniqueMapper(options, strings.TrimPrefix(wrapper.Field.TypeStr, "*"), false)
+			if !ok {
+				continue
+			}
+			castStr, castAddr, ok := nestedCallStr("v", srcType, wrapper.Field.TypeStr, mapper, options)
+			if !ok || (mapper.ReturnsError && !options.returnsError) {
+				continue
+			}
+			cases = append(cases, variantCase{
+				Type:      srcType,
+				CastStr:   castStr,
+				Checked:   mapper.ReturnsError,
+				CastAddr:  castAddr,
+				WrapType:  wrapper.Type,
+				WrapField: wrapper.Field.Name,
+			})
+		}
+		return cases, true
+	}
+	return nil, false
+}
+
+// uniqueMapper finds the single mapper of the structure type, from it when
+// fromType is set or into it otherwise, and returns the type of the other
+// side, a pointer unless the mapper takes or returns values.
+func uniqueMapper(options castOptions, structType string, fromType bool) (string, nestedMapper, bool) {
+	var found []string
+	for types := range options.mappers {
+		switch {
+		case fromType && types[0] == structType:
+			found = append(found, types[1])
+		case !fromType && types[1] == structType:
+			found = append(found, types[0])
+		}
+	}
+	if len(found) != 1 {
+		return "", nestedMapper{}, false
+	}
+	srcType, dstType := found[0], structType
+	if fromType {
+		srcType, dstType = structType, found[0]
+	}
+	mapper, ok := options.nestedMapper(srcType, dstType)
+	if !ok {
+		return "", mapper, false
+	}
+	if fromType {
+		return signaturePtr(mapper.OutputValue) + found[0], mapper, true
+	}
+	return signaturePtr(mapper.InputValue) + found[0], mapper, true
+}
+
+// oneofFieldRules returns the rules mapping the cases of the oneof fields
+// of the source messages into the flat destination fields named after
+// their wrapped fields and the flat source fields into the cases of the
+// oneof fields of the destination message. A message holds a single case,
+// so the first flat field set wins.
+func oneofFieldRules(srcList []src, dst src, options castOptions) map[string][]fieldMappingRule {
+	rules := make(map[string][]fieldMappingRule)
+	for _, srcStruct := range srcList {
+		for _, oneofName := range sortedOneofs(srcStruct.Oneofs) {
+			for _, wrapper := range srcStruct.Oneofs[oneofName] {
+				dstField := searchField(dst.Fields, wrapper.Field.Name)
+				if dstField == nil {
+					continue
+				}
+				castStr, ok := castDstField("v", wrapper.Field, *dstField, options)
+				var checked, castAddr bool
+				if !ok {
+					castStr, checked, castAddr, ok = castNestedField("v", wrapper.Field, *dstField, options)
+				}
+				if !ok {
+					continue
+				}
+				rules[dstField.Name] = append(rules[dstField.Name], fieldMappingRule{
+					DstFieldName: dstField.Name,
+					SrcAlias:     srcStruct.Alias,
+					SrcShortPath: srcStruct.ShortPath,
+					SrcFieldName: oneofName,
+					CastStr:      fmt.Sprintf("%s.%s", srcStruct.Alias, oneofName),
+					Casted:       true,
+					Variants:     []variantCase{{Type: "*" + wrapper.Type, CastStr: castStr, Checked: checked, CastAddr: castAddr, DstFieldName: dstField.Name}},
+				})
+			}
+		}
+	}
+	for _, oneofName := range sortedOneofs(dst.Oneofs) {
+		for _, srcStruct := range srcList {
+			for _, wrapper := range dst.Oneofs[oneofName] {
+				srcField := searchField(srcStruct.Fields, wrapper.Field.Name)
+				if srcField == nil {
+					continue
+				}
+				guard := nonZeroStr(fmt.Sprintf("%s.%s", srcStruct.Alias, srcField.Name), *srcField)
+				castStr, ok := castDstField(srcStruct.Alias, *srcField, wrapper.Field, options)
+				if !ok {
+					var checked bool
+					castStr, checked, _, ok = castNestedField(srcStruct.Alias, *srcField, wrapper.Field, options)
+					ok = ok && !checked
+				}
+				if !ok || len(guard) == 0 {
+					continue
+				}
+				rules[oneofName] = append(rules[oneofName], fieldMappingRule{
+					DstFieldName: oneofName,
+					SrcAlias:     srcStruct.Alias,
+					SrcShortPath: srcStruct.ShortPath,
+					SrcFieldName: srcField.Name,
+					CastStr:      fmt.Sprintf("&%s{%s: %s}", wrapper.Type, wrapper.Field.Name, castStr),
+					Casted:       true,
+					Guard:        joinGuards(fmt.Sprintf("%s.%s == nil", dst.Alias, oneofName), guard),
+				})
+			}
+		}
+	}
+	return rules
+}
+
+// mergeOneofRules merges the rules mapping the cases of a oneof field into
+// flat destination fields under the same guard, the first one switching
+// over the oneof once with the cases of all.
+func mergeOneofRules(rules []fieldMappingRule) []fieldMappingRule {
+	merged := make([]fieldMappingRule, 0, len(rules))
+	first := make(map[[3]string]int)
+	for _, rule := range rules {
+		if len(rule.Variants) == 0 || len(rule.Variants[0].DstFieldName) == 0 || len(rule.DstKey) != 0 {
+			merged = append(merged, rule)
+			continue
+		}
+		key := [3]string{rule.SrcAlias, rule.CastStr, rule.Guard}
+		if i, exist := first[key]; exist {
+			merged[i].Variants = append(merged[i].Variants[:len(merged[i].Variants):len(merged[i].Variants)], rule.Variants...)
+			continue
+		}
+		first[key] = len(merged)
+		merged = append(merged, rule)
+	}
+	return merged
+}
+
+// hasCastedRule reports whether one of the rules maps the field.
+func hasCastedRule(rules []fieldMappingRule) bool {
+	for _, rule := range rules {
+		if rule.Casted {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedOneofs(oneofs map[string][]oneofWrapper) []string {
+	names := make([]string, 0, len(oneofs))
+	for name := range oneofs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
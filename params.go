@@ -0,0 +1,82 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+// paramConfig is an extra parameter of the mapper signatures, relations may
+// refer to it and it fills the destination field of its name.
+type paramConfig struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+}
+
+// validateParams checks the extra parameters are distinct identifiers
+// clashing with no other parameter of the mapper.
+func validateParams(mapperConfig mapperConfig) error {
+	names := map[string]bool{mapperConfig.Destination.Alias: true, contextArg: true, "graph": true}
+	for _, source := range mapperConfig.Sources {
+		names[source.Alias] = true
+	}
+	for _, param := range mapperConfig.Params {
+		switch {
+		case !token.IsIdentifier(param.Name):
+			return fmt.Errorf("mapper %s: param name \"%s\" incorrect", mapperConfig.MapperName(), param.Name)
+		case len(param.Type) == 0:
+			return fmt.Errorf("mapper %s: param %s has no type", mapperConfig.MapperName(), param.Name)
+		case names[param.Name]:
+			return fmt.Errorf("mapper %s: param %s clashes with another parameter", mapperConfig.MapperName(), param.Name)
+		}
+		names[param.Name] = true
+	}
+	return nil
+}
+
+// paramArgs returns the extra parameters passed on to another mapper.
+func paramArgs(params []paramConfig) string {
+	var args string
+	for _, param := range params {
+		args += ", " + param.Name
+	}
+	return args
+}
+
+// hasParams reports whether the caller has every parameter, so it can pass
+// them on to a mapper taking them.
+func hasParams(caller, params []paramConfig) bool {
+	for _, param := range params {
+		var found bool
+		for _, callerParam := range caller {
+			if callerParam == param {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// castParamField fills the destination field with the parameter of its
+// name, case aside, and of its type or of the type it points to.
+func castParamField(params []paramConfig, dstField field) (string, bool) {
+	for _, param := range params {
+		if !strings.EqualFold(param.Name, dstField.Name) {
+			continue
+		}
+		switch dstField.TypeStr {
+		case param.Type:
+			return param.Name, true
+		case "*" + param.Type:
+			return "&" + param.Name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,136 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"log"
+	"strings"
+)
+
+const (
+	wrapperspbPackage  = "google.golang.org/protobuf/types/known/wrapperspb"
+	structpbPackage    = "google.golang.org/protobuf/types/known/structpb"
+	timestamppbPackage = "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// protoWrapperTypes maps the protobuf well-known wrapper messages to the Go
+// type they wrap and to the wrapperspb constructor building them.
+var protoWrapperTypes = map[string]struct {
+	ValueType   string
+	Constructor string
+}{
+	"DoubleValue": {ValueType: "float64", Constructor: "Double"},
+	"FloatValue":  {ValueType: "float32", Constructor: "Float"},
+	"Int64Value":  {ValueType: "int64", Constructor: "Int64"},
+	"UInt64Value": {ValueType: "uint64", Constructor: "UInt64"},
+	"Int32Value":  {ValueType: "int32", Constructor: "Int32"},
+	"UInt32Value": {ValueType: "uint32", Constructor: "UInt32"},
+	"BoolValue":   {ValueType: "bool", Constructor: "Bool"},
+	"StringValue": {ValueType: "string", Constructor: "String"},
+	"BytesValue":  {ValueType: "[]byte", Constructor: "Bytes"},
+}
+
+// structpbTypes maps the structpb messages to the Go type they represent and
+// to the fallible structpb constructor building them.
+var structpbTypes = map[string]struct {
+	ValueType   string
+	Getter      string
+	Constructor string
+}{
+	"Struct":    {ValueType: "map[string]any", Getter: "AsMap", Constructor: "NewStruct"},
+	"ListValue": {ValueType: "[]any", Getter: "AsSlice", Constructor: "NewList"},
+	"Value":     {ValueType: "any", Getter: "AsInterface", Constructor: "NewValue"},
+}
+
+// protoInternalFields are the bookkeeping fields protoc-gen-go adds to every
+// generated message. They are never mapped.
+var protoInternalFields = map[string]bool{
+	"state":         true,
+	"sizeCache":     true,
+	"unknownFields": true,
+}
+
+// isProtoMessage reports whether the structure was generated by protoc-gen-go.
+func isProtoMessage(s *ast.StructType) bool {
+	for _, f := range s.Fields.List {
+		if typeStrValue(f.Type) == "protoimpl.MessageState" {
+			return true
+		}
+	}
+	return false
+}
+
+// protoMessageName returns the name of the message of the package the
+// pointer type refers to. Field types are spelled with the alias reserved to
+// their package, so aliased imports are told apart.
+func protoMessageName(typeStr, packagePath string) (string, bool) {
+	prefix := fmt.Sprintf("*%s.", reservePackageAlias(packagePath))
+	if !strings.HasPrefix(typeStr, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(typeStr, prefix), true
+}
+
+// castWellKnownType converts between protobuf well-known types and the plain
+// Go types they represent. Building structpb messages may fail, so
+// castStructpbField converts into them.
+func castWellKnownType(srcRow, srcType, dstType string, options castOptions) (string, bool) {
+	if name, ok := protoMessageName(srcType, wrapperspbPackage); ok {
+		if wrapper, ok := protoWrapperTypes[name]; ok {
+			return castRow(fmt.Sprintf("%s.GetValue()", srcRow), wrapper.ValueType, dstType, options)
+		}
+	}
+	if name, ok := protoMessageName(dstType, wrapperspbPackage); ok {
+		if wrapper, ok := protoWrapperTypes[name]; ok {
+			if castStr, ok := castRow(srcRow, srcType, wrapper.ValueType, options); ok {
+				return fmt.Sprintf("%s.%s(%s)", getPackageAlias(wrapperspbPackage), wrapper.Constructor, castStr), true
+			}
+			return srcRow, false
+		}
+	}
+	if name, ok := protoMessageName(srcType, timestamppbPackage); ok && name == "Timestamp" {
+		return castRow(fmt.Sprintf("%s.AsTime()", srcRow), "time.Time", dstType, options)
+	}
+	if name, ok := protoMessageName(srcType, structpbPackage); ok {
+		if structpbType, ok := structpbTypes[name]; ok {
+			return castRow(fmt.Sprintf("%s.%s()", srcRow, structpbType.Getter), structpbType.ValueType, dstType, options)
+		}
+	}
+	if name, ok := protoMessageName(dstType, timestamppbPackage); ok && name == "Timestamp" {
+		if castStr, ok := castRow(srcRow, srcType, "time.Time", options); ok {
+			return fmt.Sprintf("%s.New(%s)", getPackageAlias(timestamppbPackage), castStr), true
+		}
+	}
+	return srcRow, false
+}
+
+// castStructpbField fills the rule building the structpb destination field
+// from the map, the slice or the value of the source field. The values
+// structpb can't represent fail the mapper, so mappers not returning an
+// error leave the field unmapped.
+func castStructpbField(rule fieldMappingRule, srcAlias string, srcField, dstField field, options castOptions) (fieldMappingRule, bool) {
+	name, ok := protoMessageName(dstField.TypeStr, structpbPackage)
+	if !ok {
+		return rule, false
+	}
+	structpbType, ok := structpbTypes[name]
+	if !ok {
+		return rule, false
+	}
+	castStr, ok := castRow(fmt.Sprintf("%s.%s", srcAlias, srcField.Name), srcField.TypeStr, structpbType.ValueType, options)
+	if !ok {
+		return rule, false
+	}
+	if !options.returnsError {
+		log.Printf("%s: destination field %s left unmapped, building %s may fail and the mapper returns no error",
+			options.mapperName, dstField.Name, dstField.TypeStr)
+		return rule, false
+	}
+	rule.CastStr = fmt.Sprintf("%s.%s(%s)", getPackageAlias(structpbPackage), structpbType.Constructor, castStr)
+	rule.Checked, rule.Casted = true, true
+	return rule, true
+}
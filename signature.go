@@ -0,0 +1,84 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import "fmt"
+
+const (
+	signaturePointer = "pointer"
+	signatureValue   = "value"
+)
+
+// InputValue reports whether the mapper takes its sources by value. Map
+// sources are always values.
+func (mc mapperConfig) InputValue() bool {
+	return mc.Input == signatureValue || mc.MapSource()
+}
+
+// OutputValue reports whether the mapper returns its destination by value.
+// Map destinations are always values.
+func (mc mapperConfig) OutputValue() bool {
+	return mc.Output == signatureValue || mc.MapDestination()
+}
+
+// ListInputValue reports whether the list and the index mappers take []T
+// lists, by default when the mapper takes its sources by value.
+func (mc mapperConfig) ListInputValue() bool {
+	if len(mc.ListInput) == 0 {
+		return mc.InputValue()
+	}
+	return mc.ListInput == signatureValue
+}
+
+// ListOutputValue reports whether the list and the index mappers return
+// the destinations by value, by default when the mapper returns its
+// destination by value.
+func (mc mapperConfig) ListOutputValue() bool {
+	if len(mc.ListOutput) == 0 {
+		return mc.OutputValue()
+	}
+	return mc.ListOutput == signatureValue
+}
+
+func validateSignature(mapperConfig mapperConfig) error {
+	for _, form := range []string{mapperConfig.Input, mapperConfig.Output, mapperConfig.ListInput, mapperConfig.ListOutput} {
+		switch form {
+		case "", signaturePointer, signatureValue:
+		default:
+			return fmt.Errorf("mapper %s: unknown signature form \"%s\"", mapperConfig.MapperName(), form)
+		}
+	}
+	return nil
+}
+
+// signaturePtr returns the prefix of the types passed by pointer.
+func signaturePtr(value bool) string {
+	if value {
+		return ""
+	}
+	return "*"
+}
+
+// signatureAddr returns the operator taking the address of the values, so
+// hooks get pointers either way.
+func signatureAddr(value bool) string {
+	if value {
+		return "&"
+	}
+	return ""
+}
+
+// formStr adapts the expression of the structure from its value or pointer
+// form to the other one, nil pointers becoming zero values. Values are
+// taken by the address, so the expression must be addressable.
+func formStr(expr, shortPath string, fromValue, toValue bool, options castOptions) string {
+	switch {
+	case fromValue == toValue:
+		return expr
+	case fromValue:
+		return "&" + expr
+	}
+	return derefStr(shortPath, expr, options)
+}
@@ -0,0 +1,69 @@
+package model
+
+const DefaultZip = "190000"
+
+type Address struct {
+	City string
+	Zip  string
+}
+
+type AddressDTO struct {
+	City string
+	Zip  string
+}
+
+type User struct {
+	ID    int64
+	Name  string
+	Email *string
+	Age   int32
+	Tags  []string
+	Home  *Address
+	Addrs []*Address
+}
+
+type UserDTO struct {
+	ID    int64
+	Name  string
+	Email string
+	Age   int64
+	Tags  []string
+	Home  *AddressDTO
+	Addrs []*AddressDTO
+}
+
+type Doc struct {
+	Title string     `json:"title"`
+	Home  *Address   `json:"home,omitempty"`
+	Main  Address    `json:"main"`
+	Addrs []Address  `json:"addrs"`
+	Refs  []*Address `json:"refs"`
+}
+
+// Member is shaped as protoc-gen-go generates a message with a oneof.
+type Member struct {
+	Name    string
+	Contact isMember_Contact `protobuf_oneof:"contact"`
+}
+
+type isMember_Contact interface {
+	isMember_Contact()
+}
+
+type Member_Email struct {
+	Email string `protobuf:"bytes,2,opt,name=email,proto3,oneof"`
+}
+
+type Member_Phone struct {
+	Phone string `protobuf:"bytes,3,opt,name=phone,proto3,oneof"`
+}
+
+func (*Member_Email) isMember_Contact() {}
+
+func (*Member_Phone) isMember_Contact() {}
+
+type MemberDTO struct {
+	Name  string
+	Email string
+	Phone string
+}
@@ -0,0 +1,9 @@
+package out
+
+import "example/model"
+
+// Converter is implemented by the generated file of its own package.
+type Converter interface {
+	ToDTO(src *model.User) *model.UserDTO
+	ToDTOs(src []*model.User) []*model.UserDTO
+}
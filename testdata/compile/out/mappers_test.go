@@ -0,0 +1,77 @@
+package out
+
+import (
+	"reflect"
+	"testing"
+
+	"example/model"
+)
+
+func TestUserDTOMapper(t *testing.T) {
+	email := "ann@example.com"
+	user := &model.User{
+		ID:    1,
+		Name:  "Ann",
+		Email: &email,
+		Age:   30,
+		Tags:  []string{"admin"},
+		Home:  &model.Address{City: "Oslo", Zip: "0150"},
+		Addrs: []*model.Address{{City: "Bergen", Zip: "5003"}},
+	}
+	want := &model.UserDTO{
+		ID:    1,
+		Name:  "Ann",
+		Email: email,
+		Age:   30,
+		Tags:  []string{"admin"},
+		Home:  &model.AddressDTO{City: "Oslo", Zip: "0150"},
+		Addrs: []*model.AddressDTO{{City: "Bergen", Zip: "5003"}},
+	}
+	if got := UserDTOMapper(user); !reflect.DeepEqual(got, want) {
+		t.Errorf("UserDTOMapper() = %+v, want %+v", got, want)
+	}
+	if got := UserDTOMapper(nil); got != nil {
+		t.Errorf("UserDTOMapper(nil) = %+v, want nil", got)
+	}
+}
+
+func TestAddressDTOMapperDefaults(t *testing.T) {
+	tests := []struct {
+		src  *model.Address
+		want *model.AddressDTO
+	}{
+		{src: &model.Address{}, want: &model.AddressDTO{City: "St.Petersburg", Zip: model.DefaultZip}},
+		{src: &model.Address{City: "Oslo", Zip: "0150"}, want: &model.AddressDTO{City: "Oslo", Zip: "0150"}},
+	}
+	for _, tt := range tests {
+		if got := AddressDTOMapper(tt.src); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("AddressDTOMapper(%+v) = %+v, want %+v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestConverter(t *testing.T) {
+	var converter Converter = NewConverter()
+	users := []*model.User{{ID: 1, Name: "Ann"}, {ID: 2, Name: "Bob"}}
+	got := converter.ToDTOs(users)
+	if len(got) != 2 || got[1].Name != "Bob" || converter.ToDTO(users[0]).ID != 1 {
+		t.Errorf("Converter.ToDTOs() = %+v", got)
+	}
+}
+
+func TestMemberDTOMapper(t *testing.T) {
+	tests := []struct {
+		src  *model.Member
+		want *model.MemberDTO
+	}{
+		{src: &model.Member{Name: "Ann", Contact: &model.Member_Email{Email: "ann@example.com"}}, want: &model.MemberDTO{Name: "Ann", Email: "ann@example.com"}},
+		{src: &model.Member{Name: "Bob", Contact: &model.Member_Phone{Phone: "555"}}, want: &model.MemberDTO{Name: "Bob", Phone: "555"}},
+		{src: &model.Member{Name: "Eve"}, want: &model.MemberDTO{Name: "Eve"}},
+		{src: &model.Member{Name: "Joe", Contact: (*model.Member_Email)(nil)}, want: &model.MemberDTO{Name: "Joe"}},
+	}
+	for _, tt := range tests {
+		if got := MemberDTOMapper(tt.src); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MemberDTOMapper(%+v) = %+v, want %+v", tt.src, got, tt.want)
+		}
+	}
+}
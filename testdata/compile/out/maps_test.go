@@ -0,0 +1,57 @@
+package out
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"example/model"
+)
+
+func TestDocMapRoundTrip(t *testing.T) {
+	doc := &model.Doc{
+		Title: "Offices",
+		Home:  &model.Address{City: "Oslo", Zip: "0150"},
+		Main:  model.Address{City: "Bergen", Zip: "5003"},
+		Addrs: []model.Address{{City: "Trondheim", Zip: "7010"}, {City: "Tromsø", Zip: "9008"}},
+		Refs:  []*model.Address{{City: "Stavanger", Zip: "4006"}},
+	}
+	m := DocToMapMapper(doc)
+	got, err := DocFromMapMapper(m)
+	if err != nil {
+		t.Fatalf("DocFromMapMapper() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, doc) {
+		t.Errorf("DocFromMapMapper(DocToMapMapper()) = %+v, want %+v", got, doc)
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	got, err = DocFromMapMapper(decoded)
+	if err != nil {
+		t.Fatalf("DocFromMapMapper() of JSON error = %v", err)
+	}
+	if !reflect.DeepEqual(got, doc) {
+		t.Errorf("DocFromMapMapper() of JSON = %+v, want %+v", got, doc)
+	}
+}
+
+func TestMapMappersWithoutAlias(t *testing.T) {
+	want := &model.AddressDTO{City: "Oslo", Zip: "0150"}
+	got, err := AddressDTOFromMapMapper(AddressDTOToMapMapper(want))
+	if err != nil {
+		t.Fatalf("AddressDTOFromMapMapper() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AddressDTOFromMapMapper(AddressDTOToMapMapper()) = %+v, want %+v", got, want)
+	}
+	if m := UserToMapMapper(&model.User{Name: "Ann"}); m["Name"] != "Ann" {
+		t.Errorf("UserToMapMapper()[\"Name\"] = %v, want Ann", m["Name"])
+	}
+}
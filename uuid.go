@@ -0,0 +1,89 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const uuidPackage = "github.com/google/uuid"
+
+// uuidType returns the type github.com/google/uuid.UUID is spelled with in
+// the field types, the structure files importing it by whatever name, empty
+// when no structure refers to it. UUIDs of other packages keep their own
+// alias, so they don't convert like it.
+func uuidType() string {
+	for alias, path := range reservedPackageAliasMap {
+		if path == uuidPackage {
+			return alias + ".UUID"
+		}
+	}
+	return ""
+}
+
+// knownArrayType returns the underlying array type of the named arrays of
+// other packages, so they convert like the arrays.
+func knownArrayType(typeStr string) (string, bool) {
+	if typeStr == uuidType() && len(typeStr) != 0 {
+		return "[16]byte", true
+	}
+	return "", false
+}
+
+// useArrayImports imports the packages of the known named arrays the types,
+// their elements or the values they point to are.
+func useArrayImports(types ...string) {
+	uuidType := uuidType()
+	if len(uuidType) == 0 {
+		return
+	}
+	for _, typeStr := range types {
+		for {
+			typeStr = strings.TrimLeft(typeStr, "*")
+			if typeStr == uuidType {
+				getPackageAlias(uuidPackage)
+				break
+			}
+			elem, _, ok := sequenceElem(typeStr)
+			if !ok {
+				break
+			}
+			typeStr = elem
+		}
+	}
+}
+
+// castUUIDField fills the rule formatting the uuid.UUID field into the
+// string destination field or parsing the string field into the uuid.UUID
+// destination field when options.uuidStrings is set. Parsing returns an
+// error, so only mappers returning one parse, empty strings are skipped.
+func castUUIDField(rule fieldMappingRule, srcAlias string, srcField, dstField field, options castOptions) (fieldMappingRule, bool) {
+	uuidType := uuidType()
+	if !options.uuidStrings || len(uuidType) == 0 {
+		return rule, false
+	}
+	srcRow := fmt.Sprintf("%s.%s", srcAlias, srcField.Name)
+	srcBase, dstBase := strings.TrimPrefix(srcField.TypeStr, "*"), strings.TrimPrefix(dstField.TypeStr, "*")
+	switch {
+	case srcBase == uuidType && dstBase == "string":
+		castStr, ok := castRow(srcRow+".String()", "string", dstField.TypeStr, options)
+		if !ok {
+			return rule, false
+		}
+		rule.CastStr, rule.Casted = castStr, true
+		return rule, true
+	case srcBase == "string" && dstBase == uuidType && options.returnsError:
+		if srcBase != srcField.TypeStr {
+			srcRow = "*" + srcRow
+		} else {
+			rule.Guard = joinGuards(rule.Guard, nonZeroStr(srcRow, srcField))
+		}
+		rule.CastStr = fmt.Sprintf("%s.Parse(%s)", getPackageAlias(uuidPackage), srcRow)
+		rule.CastAddr, rule.Checked, rule.Casted = dstBase != dstField.TypeStr, true, true
+		return rule, true
+	}
+	return rule, false
+}
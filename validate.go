@@ -0,0 +1,27 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import "fmt"
+
+// validationHooks returns the calls validating the mapped destination, by
+// its Validate method when it has one and by the configured validator.
+func validationHooks(mapperConfig mapperConfig, srcList []src, zero string, inlineHelpers bool) []*hookParams {
+	dstArg := signatureAddr(mapperConfig.OutputValue()) + mapperConfig.Destination.Alias
+	guard := mappedGuard(mapperConfig, srcList)
+	label := mapperConfig.MapperName() + ": validate"
+	var hooks []*hookParams
+	if mapperConfig.Validate {
+		validateFunc := "func(v any) error { if validator, ok := v.(interface{ Validate() error }); ok { return validator.Validate() }; return nil }"
+		if !inlineHelpers {
+			validateFunc = fmt.Sprintf("%s.Validate", useImport("mapping", mappingPackage))
+		}
+		hooks = append(hooks, newHook(validateFunc, []string{dstArg}, guard, true, zero, label))
+	}
+	if len(mapperConfig.Validator) != 0 {
+		hooks = append(hooks, newHook(mapperConfig.Validator, []string{dstArg}, guard, true, zero, label))
+	}
+	return hooks
+}
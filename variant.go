@@ -0,0 +1,142 @@
+// The following directive is necessary to make the package coherent:
+
+// +bкuild ignore
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"strings"
+)
+
+// variantConfig is a concrete type the interface source field holds, mapped
+// into the destination type. Field is the field of the source type holding
+// the value, e.g. the field of a protobuf oneof wrapper. The types are
+// pointers unless Input or Output is value.
+type variantConfig struct {
+	Source      string `yaml:"source"`
+	Field       string `yaml:"field"`
+	Destination string `yaml:"destination"`
+	Input       string `yaml:"input"`
+	Output      string `yaml:"output"`
+}
+
+// variantCase is a case of the type switch mapping an interface field,
+// CastStr maps the value v of the case type. Checked and CastAddr are
+// those of the rules, WrapType and WrapField wrap the mapped value into a
+// protobuf oneof wrapper. DstFieldName is the flat destination field the
+// case of a oneof assigns, the field of the rule by default.
+type variantCase struct {
+	Type         string
+	CastStr      string
+	Checked      bool
+	CastAddr     bool
+	WrapType     string
+	WrapField    string
+	DstFieldName string
+}
+
+// Nilable reports whether the case type is a pointer, the interface holding
+// a nil one being left unmapped.
+func (variantCase variantCase) Nilable() bool {
+	return strings.HasPrefix(variantCase.Type, "*")
+}
+
+// Value returns the value the case assigns given the mapped row.
+func (variantCase variantCase) Value(row string) string {
+	if variantCase.CastAddr {
+		row = "&" + row
+	}
+	if len(variantCase.WrapType) != 0 {
+		return fmt.Sprintf("&%s{%s: %s}", variantCase.WrapType, variantCase.WrapField, row)
+	}
+	return row
+}
+
+// interfaceStr returns the interface type as written, interface{} when it
+// declares no method.
+func interfaceStr(interfaceType *ast.InterfaceType) string {
+	if interfaceType.Methods == nil || len(interfaceType.Methods.List) == 0 {
+		return "interface{}"
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, token.NewFileSet(), interfaceType); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// isInterface reports whether the type is an interface, predeclared or
+// declared in the package of the structure.
+func isInterface(typeExpr ast.Expr, types map[string]*ast.TypeSpec) bool {
+	switch t := typeExpr.(type) {
+	case *ast.InterfaceType:
+		return true
+	case *ast.Ident:
+		if t.Name == "any" || t.Name == "error" {
+			return true
+		}
+		if ts, exist := types[t.Name]; exist {
+			_, ok := ts.Type.(*ast.InterfaceType)
+			return ok
+		}
+	}
+	return false
+}
+
+// isEmptyInterface reports whether any value can be assigned to the type.
+func isEmptyInterface(typeStr string) bool {
+	return typeStr == "any" || typeStr == "interface{}"
+}
+
+// variantCases returns the cases of the type switch mapping the variants
+// the source field holds into the destination field, calling the mapper of
+// each variant unless it is of the destination type.
+func variantCases(dir string, dstField field, variants []variantConfig, options castOptions) ([]variantCase, error) {
+	cases := make([]variantCase, 0, len(variants))
+	for _, variant := range variants {
+		for _, form := range []string{variant.Input, variant.Output} {
+			switch form {
+			case "", signaturePointer, signatureValue:
+			default:
+				return nil, fmt.Errorf("field %s: unknown variant form \"%s\"", dstField.Name, form)
+			}
+		}
+		srcMeta, err := parseStructure(dir, variant.Source)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", dstField.Name, err)
+		}
+		dstMeta, err := parseStructure(dir, variant.Destination)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", dstField.Name, err)
+		}
+		variantCase := variantCase{Type: signaturePtr(variant.Input == signatureValue) + shortPath(srcMeta)}
+		valueRow, valueType := "v", variantCase.Type
+		if len(variant.Field) != 0 {
+			valueField := searchField(structFields(srcMeta), variant.Field)
+			if valueField == nil {
+				return nil, fmt.Errorf("field %s: variant field %s not found in %s", dstField.Name, variant.Field, shortPath(srcMeta))
+			}
+			valueRow, valueType = "v."+valueField.Name, valueField.TypeStr
+		}
+		dstType := signaturePtr(variant.Output == signatureValue) + shortPath(dstMeta)
+		variantCase.CastStr = valueRow
+		if valueType != dstType {
+			mapper, exist := options.nestedMapper(valueType, dstType)
+			if !exist || (mapper.ReturnsError && !options.returnsError) {
+				return nil, fmt.Errorf("field %s: no mapper of variant %s into %s", dstField.Name, valueType, dstType)
+			}
+			castStr, castAddr, ok := nestedCallStr(valueRow, valueType, dstType, mapper, options)
+			if !ok {
+				return nil, fmt.Errorf("field %s: variant %s can't be mapped into %s by %s", dstField.Name, valueType, dstType, mapper.MapperFuncName)
+			}
+			variantCase.CastStr, variantCase.Checked, variantCase.CastAddr = castStr, mapper.ReturnsError, castAddr
+		}
+		cases = append(cases, variantCase)
+	}
+	return cases, nil
+}